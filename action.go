@@ -3,6 +3,7 @@ package psqlmanager
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/pressly/goose/v3"
@@ -11,31 +12,144 @@ import (
 )
 
 func RunMigrateActionInDatabase(ctx context.Context, action psqlmigrate.MigrateAction, database *db.Database, config *Config) error {
+	return runMigrateActionInDatabase(ctx, action, database, config, true)
+}
+
+// runMigrateActionInDatabase runs the migrate action on each selected
+// migration set. Set lock to false if the caller already holds the migration
+// lock on the database.
+func runMigrateActionInDatabase(ctx context.Context, action psqlmigrate.MigrateAction, database *db.Database, config *Config, lock bool) error {
+	return runMigrateActionInSchema(ctx, action, database, "", config, lock)
+}
+
+// runMigrateActionInSchema runs the migrate action on each selected
+// migration set in the schema. Uses the default search_path if schema is
+// empty.
+func runMigrateActionInSchema(ctx context.Context, action psqlmigrate.MigrateAction, database *db.Database, schema string, config *Config, lock bool) error {
 	if config == nil {
 		config = &GlobalConfig
 	}
 
+	sets, err := config.migrationSetFactoriesFor(action)
+	if err != nil {
+		return err
+	}
+
+	// Hold the migration lock across all sets, such that the action is
+	// serialized as a single unit.
+	if lock && len(sets) > 1 {
+		factory := sets[0].factory
+		if len(schema) > 0 {
+			factory = factory.ForSchema(schema)
+		}
+		release, err := lockDatabase(ctx, database, factory.MigrationLock(), config)
+		if err != nil {
+			return err
+		}
+		defer release()
+		lock = false
+	}
+
+	for _, set := range sets {
+		if len(set.name) > 0 {
+			config.logger().Info(fmt.Sprintf("MIGRATION SET \"%s\"", set.name), db.Phase("migrate"), "set", set.name)
+		}
+
+		factory := set.factory
+		if len(schema) > 0 {
+			factory = factory.ForSchema(schema)
+		}
+
+		err := runMigrateActionWithFactory(ctx, action, database, config, factory, lock)
+		if err != nil {
+			if len(set.name) > 0 {
+				return fmt.Errorf("Migration set \"%s\": %w", set.name, err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockDatabase acquires the migration lock on a separate connection to the
+// database. Does not lock if lock is nil.
+func lockDatabase(ctx context.Context, database *db.Database, lock *psqlmigrate.AdvisoryLock, config *Config) (release func(), err error) {
+	if lock == nil {
+		return func() {}, nil
+	}
+
+	conn, err := connectTarget(ctx, database, config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect for migration lock: %w", err)
+	}
+
+	unlock, err := lock.Acquire(config.logContext(ctx), conn)
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	return func() {
+		ctx := context.WithoutCancel(ctx)
+		unlock(ctx)
+		conn.Close(ctx)
+	}, nil
+}
+
+func runMigrateActionWithFactory(ctx context.Context, action psqlmigrate.MigrateAction, database *db.Database, config *Config, factory *psqlmigrate.ProviderFactory, lock bool) error {
 	if database == nil {
 		database = config.TargetDatabase()
 	}
 
 	connConfig, err := config.RootConnConfig()
-	connConfig.Database = database.Name
 	if err != nil {
 		return err
 	}
+	connConfig.Database = database.Name
+
+	if vars := config.SqlVars(database); vars != nil {
+		factory = factory.Copy()
+		factory.Vars = vars
+	}
 
-	runner, err := config.migrationProviderFactory.OpenRunner(ctx, connConfig)
+	runner, err := factory.OpenRunner(ctx, connConfig)
 	if err != nil {
 		return err
 	}
 	defer runner.Close()
 
-	result, err := runner.Run(ctx, action)
-	fmt.Println(result.String())
+	if !lock {
+		runner.Lock = nil
+	}
+
+	result, err := runner.Run(config.logContext(ctx), action)
+	logMigrateActionResult(config.logger(), result)
 	return err
 }
 
+func logMigrateActionResult(logger *slog.Logger, result *psqlmigrate.MigrateActionResult) {
+	if result == nil {
+		return
+	}
+
+	status := "SUCCESS"
+	if result.Err != nil {
+		status = "FAILED"
+	}
+	action := "nil"
+	if result.Action != nil {
+		action = result.Action.String()
+	}
+
+	logger.Info(fmt.Sprintf("MIGRATE ACTION '%s'", action),
+		db.Phase("migrate"),
+		db.StatusKey, status,
+		db.DurationKey, result.Duration,
+	)
+	psqlmigrate.LogMigrationResultsTo(logger, result.Results...)
+}
+
 func RunMigrateAction(ctx context.Context, action psqlmigrate.MigrateAction, config *Config) error {
 	return RunMigrateActionInDatabase(ctx, action, nil, config)
 }
@@ -45,8 +159,10 @@ func RunSeedersWithConn(ctx context.Context, conn *pgx.Conn, config *Config) err
 		config = &GlobalConfig
 	}
 
-	fmt.Printf(">> RUN SEEDERS seed=%s\n", config.SeederRunner.Seed)
-	return config.SeederRunner.Run(ctx, conn)
+	config.logger().Info(fmt.Sprintf("RUN SEEDERS seed=%s", config.SeederRunner.Seed), db.Phase("seed"))
+	seederRunner := config.SeederRunner
+	seederRunner.Vars = config.SqlVars(&db.Database{Name: conn.Config().Database})
+	return seederRunner.Run(config.logContext(ctx), conn)
 }
 
 func RunSeeders(ctx context.Context, config *Config) error {
@@ -79,7 +195,12 @@ func MigrationStatus(ctx context.Context, config *Config) ([]*goose.MigrationSta
 		return nil, err
 	}
 
-	provider, err := config.migrationProviderFactory.OpenProvider(ctx, connConfig)
+	factory, err := config.singleMigrationSetFactory()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := factory.OpenProvider(ctx, connConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +209,64 @@ func MigrationStatus(ctx context.Context, config *Config) ([]*goose.MigrationSta
 	return provider.Status(ctx)
 }
 
+// MigrationStatusReport reads the migration status of each selected
+// migration set.
+func MigrationStatusReport(ctx context.Context, config *Config) ([]*psqlmigrate.StatusReport, error) {
+	return MigrationStatusReportInDatabase(ctx, nil, config)
+}
+
+// MigrationStatusReportInDatabase reads the migration status of each
+// selected migration set in the database.
+func MigrationStatusReportInDatabase(ctx context.Context, database *db.Database, config *Config) ([]*psqlmigrate.StatusReport, error) {
+	return migrationStatusReportInSchema(ctx, database, "", config)
+}
+
+func migrationStatusReportInSchema(ctx context.Context, database *db.Database, schema string, config *Config) ([]*psqlmigrate.StatusReport, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+	if database == nil {
+		database = config.TargetDatabase()
+	}
+
+	connConfig, err := config.RootConnConfig()
+	if err != nil {
+		return nil, err
+	}
+	connConfig.Database = database.Name
+
+	sets, err := config.migrationSetFactories()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*psqlmigrate.StatusReport, len(sets))
+	for i, set := range sets {
+		factory := set.factory
+		if len(schema) > 0 {
+			factory = factory.ForSchema(schema)
+		}
+
+		reports[i], err = readStatusReport(ctx, connConfig, factory)
+		if err != nil {
+			return nil, err
+		}
+		reports[i].Set = set.name
+	}
+
+	return reports, nil
+}
+
+func readStatusReport(ctx context.Context, connConfig *pgx.ConnConfig, factory *psqlmigrate.ProviderFactory) (*psqlmigrate.StatusReport, error) {
+	runner, err := factory.OpenRunner(ctx, connConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer runner.Close()
+
+	return runner.StatusReport(ctx)
+}
+
 func MigrationSources(ctx context.Context, config *Config) ([]*goose.Source, error) {
 	if config == nil {
 		config = &GlobalConfig
@@ -98,7 +277,12 @@ func MigrationSources(ctx context.Context, config *Config) ([]*goose.Source, err
 		return nil, err
 	}
 
-	provider, err := config.migrationProviderFactory.OpenProvider(ctx, connConfig)
+	factory, err := config.singleMigrationSetFactory()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := factory.OpenProvider(ctx, connConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +309,7 @@ func dropDatabaseIfExists(ctx context.Context, rootConn *pgx.Conn, database *db.
 		return true, err
 	}
 
-	fmt.Printf(">> Dropped Database \"%s\"\n", database.Name)
+	config.logger().Info(fmt.Sprintf("Dropped Database \"%s\"", database.Name), db.Phase("drop"), "database", database.Name)
 	return true, nil
 }
 
@@ -146,7 +330,7 @@ func DropDatabaseIfExists(ctx context.Context, database *db.Database, config *Co
 	}
 
 	if !existed {
-		fmt.Printf(">> SKIP Drop Database \"%s\" (does not exist...)\n", database.Name)
+		config.logger().Info(fmt.Sprintf("SKIP Drop Database \"%s\" (does not exist...)", database.Name), db.Phase("drop"), "database", database.Name)
 	}
 
 	return nil
@@ -0,0 +1,74 @@
+package psqlmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+)
+
+// BaselineAction brings an existing database under migration control by
+// marking all migrations up to and including Version as applied, without
+// running them.
+type BaselineAction struct {
+	*db.Database
+	Version int64
+
+	// Compare the schema of the database with the schema that the
+	// migrations produce at Version first, and refuse to mark the
+	// migrations as applied if they differ.
+	Verify  bool
+	Options db.SchemaOptions
+}
+
+// RunWithRootConn runs the action. If the schemas differ when verifying, it
+// returns the differences together with the error.
+func (a *BaselineAction) RunWithRootConn(ctx context.Context, rootConn *pgx.Conn, config *Config) (*db.SchemaDiff, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+
+	database := a.Database
+	if database == nil {
+		database = config.TargetDatabase()
+	}
+
+	sets, err := config.migrationSetFactories()
+	if err != nil {
+		return nil, fmt.Errorf("Failed BaselineAction \"%s\": %w", database.Name, err)
+	}
+	if len(sets) > 1 {
+		return nil, fmt.Errorf("Failed BaselineAction \"%s\": Multiple migration sets selected. Select one of '%s'", database.Name, strings.Join(config.MigrationSetNames(), "', '"))
+	}
+
+	var diff *db.SchemaDiff
+	if a.Verify {
+		diff, err = diffWithMigrations(ctx, rootConn, database, config, sets, []int64{a.Version}, a.Options)
+		if err != nil {
+			return nil, fmt.Errorf("Failed BaselineAction \"%s\": Verify: %w", database.Name, err)
+		}
+		if !diff.Empty() {
+			return diff, fmt.Errorf("Failed BaselineAction \"%s\": Schema differs from version %d in %d objects", database.Name, a.Version, len(diff.Entries))
+		}
+	}
+
+	err = runMigrateActionWithFactory(ctx, psqlmigrate.BaselineAction(a.Version), database, config, sets[0].factory, true)
+	if err != nil {
+		return diff, fmt.Errorf("Failed BaselineAction \"%s\": %w", database.Name, err)
+	}
+
+	return diff, nil
+}
+
+func (a *BaselineAction) Run(ctx context.Context, config *Config) (*db.SchemaDiff, error) {
+	rootConn, err := ConnectRootDB(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer rootConn.Close(ctx)
+
+	return a.RunWithRootConn(ctx, rootConn, config)
+}
@@ -3,11 +3,11 @@ package cli
 import (
 	"fmt"
 	"os"
-	"strings"
+	"strconv"
 
-	"github.com/pressly/goose/v3"
 	psqlmanager "github.com/shared-digitaltechnologies/psql-manager"
 	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlinit "github.com/shared-digitaltechnologies/psql-manager/init"
 	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
 	"github.com/spf13/cobra"
 )
@@ -17,9 +17,13 @@ type Cli struct {
 	*cobra.Command
 
 	flags struct {
-		cli     cliFlags
-		connect connectFlags
-		seed    seedOpt
+		cli      cliFlags
+		connect  connectFlags
+		lock     lockFlags
+		sets     migrationSetFlags
+		vars     sqlVarsFlags
+		timeouts timeoutFlags
+		seed     seedOpt
 	}
 }
 
@@ -27,6 +31,7 @@ var (
 	migrateGroup = &cobra.Group{ID: "migrate", Title: "Database migration commands:"}
 	seedGroup    = &cobra.Group{ID: "seed", Title: "Database seeding commands:"}
 	tempGroup    = &cobra.Group{ID: "temp", Title: "Temporary database commands:"}
+	initGroup    = &cobra.Group{ID: "init", Title: "Database initialization commands:"}
 )
 
 func NewCli(name string, config *psqlmanager.Config) Cli {
@@ -47,20 +52,70 @@ func NewCli(name string, config *psqlmanager.Config) Cli {
 			_ = config.Extend(
 				cli.flags.cli.applyToConfig,
 				cli.flags.connect.applyToConfig,
+				cli.flags.lock.applyToConfig,
+				cli.flags.sets.applyToConfig,
+				cli.flags.vars.applyToConfig,
+				cli.flags.timeouts.applyToConfig,
 			)
 		},
 	}
 	addCliFlags(rootCmd.PersistentFlags(), &cli.flags.cli)
 	addConnectFlags(rootCmd.PersistentFlags(), &cli.flags.connect, cli.Config)
+	addLockFlags(rootCmd.PersistentFlags(), &cli.flags.lock, cli.Config)
+	addMigrationSetFlags(rootCmd.PersistentFlags(), &cli.flags.sets)
+	addSqlVarsFlags(rootCmd.PersistentFlags(), &cli.flags.vars)
+	addTimeoutFlags(rootCmd.PersistentFlags(), &cli.flags.timeouts)
 	rootCmd.AddGroup(
 		migrateGroup,
 		seedGroup,
 		tempGroup,
+		initGroup,
 	)
 
 	cli.Command = &rootCmd
 
 	// Migrate commands
+	var allowMissing bool
+	addAllowMissingFlag := func(cmd *cobra.Command) {
+		cmd.Flags().BoolVar(&allowMissing, "allow-missing", allowMissing, "Apply pending migrations that are older than the current version.")
+	}
+	applyAllowMissing := func() error {
+		if !allowMissing {
+			return nil
+		}
+		return cli.Config.Extend(psqlmanager.WithAllowMissingMigrations(true))
+	}
+
+	var atomic bool
+	addAtomicFlag := func(cmd *cobra.Command) {
+		cmd.Flags().BoolVar(&atomic, "atomic", atomic, "Run all migrations in a single transaction. Rolls back all of them if one fails.")
+	}
+
+	var databases databasesFlags
+	runMigrateAction := func(cmd *cobra.Command, action psqlmigrate.MigrateAction) error {
+		if err := databases.validate(); err != nil {
+			return err
+		}
+		if atomic {
+			action = psqlmigrate.AtomicAction(action)
+		}
+
+		var results []*psqlmanager.DatabaseResult
+		var err error
+		switch {
+		case databases.enabled():
+			results, err = psqlmanager.RunMigrateActionInDatabases(cmd.Context(), action, databases.opts(), cli.Config)
+		case databases.perSchema():
+			results, err = psqlmanager.RunMigrateActionPerSchema(cmd.Context(), action, nil, databases.schemasOpts(), cli.Config)
+		default:
+			return psqlmanager.RunMigrateAction(cmd.Context(), action, cli.Config)
+		}
+
+		writeDatabaseResults(os.Stdout, results)
+		return err
+	}
+
+	var upStrict bool
 	upCmd := &cobra.Command{
 		Use:   "up [+DELTA|VERSION]",
 		Args:  cobra.MaximumNArgs(1),
@@ -72,18 +127,35 @@ The first argument determines the version to which the database will be migrated
  - If the argument is '+DELTA', it will use the DELTA version later than the current version.
  - If the argument is 'VERSION' (without +), migrates to that specific version.
  - Migrates to the latest version if no argument is provided.
+
+With --strict, refuses to migrate if applied migrations were modified after they
+were applied.
+
+With --all-databases, migrates every database whose name matches the pattern.
+With --per-schema or --schema-query, migrates every tenant schema in the
+database, each with its own version table.
 `,
 		Aliases: []string{"u"},
 		GroupID: "migrate",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return psqlmanager.RunMigrateAction(
-				cmd.Context(),
-				psqlmigrate.UpToLatestAction,
-				cli.Config,
-			)
+			if upStrict {
+				if err := cli.Config.Extend(psqlmanager.WithStrictChecksums(true)); err != nil {
+					return err
+				}
+			}
+			if err := applyAllowMissing(); err != nil {
+				return err
+			}
+
+			return runMigrateAction(cmd, psqlmigrate.UpToLatestAction)
 		},
 	}
 
+	upCmd.Flags().BoolVar(&upStrict, "strict", upStrict, "Refuse to migrate if applied migrations were modified.")
+	addAllowMissingFlag(upCmd)
+	addAtomicFlag(upCmd)
+	addDatabasesFlags(upCmd.Flags(), &databases)
+
 	downCmd := &cobra.Command{
 		Use:   "down [-DELTA]",
 		Args:  cobra.MaximumNArgs(1),
@@ -94,11 +166,7 @@ Migrates the database down to version lower than the current version.
 		Aliases: []string{"d"},
 		GroupID: "migrate",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return psqlmanager.RunMigrateAction(
-				cmd.Context(),
-				psqlmigrate.DownByAction(1),
-				cli.Config,
-			)
+			return runMigrateAction(cmd, psqlmigrate.DownByAction(1))
 		},
 	}
 
@@ -114,11 +182,7 @@ Uses AMOUNT=1 if the first argument is omitted.
 		Aliases: []string{"r"},
 		GroupID: "migrate",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return psqlmanager.RunMigrateAction(
-				cmd.Context(),
-				psqlmigrate.RedoAction(1),
-				cli.Config,
-			)
+			return runMigrateAction(cmd, psqlmigrate.RedoAction(1))
 		},
 	}
 
@@ -131,44 +195,67 @@ Rolls back all migrations that were applied to the database.
 `,
 		GroupID: "migrate",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return psqlmanager.RunMigrateAction(
-				cmd.Context(),
-				psqlmigrate.ResetAction,
-				cli.Config,
-			)
+			return runMigrateAction(cmd, psqlmigrate.ResetAction)
 		},
 	}
 
+	addAtomicFlag(downCmd)
+	addAtomicFlag(redoCmd)
+	addAtomicFlag(resetCmd)
+
+	var statusFormat outputFormat
+	var statusCheck bool
 	statusCmd := &cobra.Command{
-		Use:     "status",
-		Args:    cobra.ExactArgs(0),
-		Short:   "Dumps the migration status of the database",
+		Use:   "status",
+		Args:  cobra.ExactArgs(0),
+		Short: "Dumps the migration status of the database",
+		Long: `
+Dumps the migration status of the database.
+
+With --check, fails if the database is not at the latest version, or if any
+migrations are missing, were applied out of order or were modified after they
+were applied.
+
+With --all-databases, dumps the status of every database whose name matches the
+pattern. With --per-schema or --schema-query, dumps a summary line with the
+version of every tenant schema in the database.
+`,
 		GroupID: "migrate",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			xs, err := psqlmanager.MigrationStatus(cmd.Context(), cli.Config)
+			if err := databases.validate(); err != nil {
+				return err
+			}
+			if databases.enabled() || databases.perSchema() {
+				return writeDatabasesStatus(cmd, statusFormat, statusCheck, &databases, cli.Config)
+			}
+
+			reports, err := psqlmanager.MigrationStatusReport(cmd.Context(), cli.Config)
 			if err != nil {
 				return err
 			}
 
-			for _, x := range xs {
-				if x.State == goose.StateApplied {
-					fmt.Printf("%05d %-60s APPLIED AT %s\n",
-						x.Source.Version,
-						x.Source.Path,
-						x.AppliedAt.Format("2006-01-02 15:04:05"),
-					)
-				} else {
-					fmt.Printf("%05d %-60s %s\n",
-						x.Source.Version,
-						x.Source.Path,
-						strings.ToUpper(string(x.State)),
-					)
-				}
+			var value any = reports
+			if len(reports) == 1 {
+				value = reports[0]
 			}
 
+			written, err := writeFormatted(os.Stdout, statusFormat, value)
+			if err != nil {
+				return err
+			}
+			if !written {
+				writeStatusTables(os.Stdout, reports)
+			}
+
+			if statusCheck {
+				return checkStatusReports(reports)
+			}
 			return nil
 		},
 	}
+	statusCmd.Flags().VarP(&statusFormat, "format", "f", "Output format (table, json or yaml).")
+	statusCmd.Flags().BoolVar(&statusCheck, "check", statusCheck, "Fail if there are pending, missing, out-of-order or modified migrations.")
+	addDatabasesFlags(statusCmd.Flags(), &databases)
 
 	migrateCmd := &cobra.Command{
 		Use:     "migrate [+/-DELTA | VERSION]",
@@ -177,13 +264,16 @@ Rolls back all migrations that were applied to the database.
 		Aliases: []string{"m"},
 		GroupID: "migrate",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return psqlmanager.RunMigrateAction(
-				cmd.Context(),
-				psqlmigrate.MigrateToAction(0),
-				cli.Config,
-			)
+			if err := applyAllowMissing(); err != nil {
+				return err
+			}
+
+			return runMigrateAction(cmd, psqlmigrate.MigrateToAction(0))
 		},
 	}
+	addAllowMissingFlag(migrateCmd)
+	addAtomicFlag(migrateCmd)
+	addDatabasesFlags(migrateCmd.Flags(), &databases)
 
 	migrationsCmd := &cobra.Command{
 		Use:     "migrations",
@@ -208,6 +298,160 @@ Rolls back all migrations that were applied to the database.
 		},
 	}
 
+	var driftFlags schemaFlags
+	driftCmd := &cobra.Command{
+		Use:   "drift [NAME]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "Detects schema drift between the database and the migrations",
+		Long: `
+Builds a temporary reference database from the migrations at the current version
+of the target database and compares the schemas of both databases.
+
+Fails if the schemas differ.
+`,
+		GroupID: "migrate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := driftFlags.schemaOptions()
+			if err != nil {
+				return err
+			}
+
+			action := psqlmanager.DriftAction{Options: opts}
+			if len(args) > 0 {
+				action.Database = &db.Database{Name: args[0]}
+			}
+
+			diff, err := action.Run(cmd.Context(), cli.Config)
+			if err != nil {
+				return err
+			}
+
+			if diff.Empty() {
+				fmt.Println(">> NO SCHEMA DRIFT DETECTED")
+				return nil
+			}
+
+			fmt.Printf(">> SCHEMA DRIFT DETECTED\n%s", diff)
+			return fmt.Errorf("Schema drift detected: %d objects differ", len(diff.Entries))
+		},
+	}
+	addSchemaFlags(driftCmd.Flags(), &driftFlags)
+
+	schemaCmd := &cobra.Command{
+		Use:     "schema <COMMAND>",
+		Short:   "Inspects the schema of the database",
+		GroupID: "migrate",
+	}
+
+	var dumpFlags schemaFlags
+	var dumpOutput string
+	schemaDumpCmd := &cobra.Command{
+		Use:   "dump [NAME]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "Dumps the schema of the database as normalized DDL",
+		Long: `
+Reads the schema of the database from pg_catalog and prints it as normalized DDL.
+
+The objects are sorted and do not contain any server specific details, such that
+the output is stable and can be committed as a golden file.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := dumpFlags.schemaOptions()
+			if err != nil {
+				return err
+			}
+
+			var database *db.Database
+			if len(args) > 0 {
+				database = &db.Database{Name: args[0]}
+			}
+
+			ddl, err := psqlmanager.DumpDatabaseSchema(cmd.Context(), database, opts, cli.Config)
+			if err != nil {
+				return err
+			}
+
+			if len(dumpOutput) == 0 || dumpOutput == "-" {
+				fmt.Print(ddl)
+				return nil
+			}
+
+			return os.WriteFile(dumpOutput, []byte(ddl), 0644)
+		},
+	}
+	addSchemaFlags(schemaDumpCmd.Flags(), &dumpFlags)
+	schemaDumpCmd.Flags().StringVarP(&dumpOutput, "output", "o", "", "Write the dump to this file instead of stdout.")
+	schemaCmd.AddCommand(schemaDumpCmd)
+
+	var baselineFlags schemaFlags
+	var baselineVerify bool
+	baselineCmd := &cobra.Command{
+		Use:   "baseline VERSION [NAME]",
+		Args:  cobra.RangeArgs(1, 2),
+		Short: "Marks the migrations up to a version as applied without running them",
+		Long: `
+Records all migrations up to and including VERSION as applied in the goose version
+table of the database, without running them. Use this to bring an existing
+database under migration control.
+
+With --verify, first compares the schema of the database with the schema that
+the migrations produce at VERSION and refuses if they differ.
+`,
+		GroupID: "migrate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("Invalid version input '%s': %v", args[0], err)
+			}
+
+			opts, err := baselineFlags.schemaOptions()
+			if err != nil {
+				return err
+			}
+
+			action := psqlmanager.BaselineAction{
+				Version: version,
+				Verify:  baselineVerify,
+				Options: opts,
+			}
+			if len(args) > 1 {
+				action.Database = &db.Database{Name: args[1]}
+			}
+
+			diff, err := action.Run(cmd.Context(), cli.Config)
+			if diff != nil && !diff.Empty() {
+				fmt.Printf(">> SCHEMA DIFFERS FROM VERSION %d\n%s", version, diff)
+			}
+			return err
+		},
+	}
+	addSchemaFlags(baselineCmd.Flags(), &baselineFlags)
+	baselineCmd.Flags().BoolVar(&baselineVerify, "verify", baselineVerify, "Compare the schema with the migrations at VERSION first.")
+
+	var squashAction psqlmanager.SquashAction
+	squashCmd := &cobra.Command{
+		Use:   "squash --upto VERSION --dir PATH",
+		Args:  cobra.ExactArgs(0),
+		Short: "Squashes the migrations up to a version into a baseline migration",
+		Long: `
+Builds a temporary database that is migrated up to VERSION, dumps its schema into
+a single baseline migration with version VERSION and moves the superseded
+migrations from the migrations directory to the archive directory.
+
+Databases that are already at or past VERSION consider the baseline applied.
+`,
+		GroupID: "migrate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := squashAction.Run(cmd.Context(), cli.Config)
+			return err
+		},
+	}
+	squashCmd.Flags().Int64Var(&squashAction.UpTo, "upto", 0, "Version of the last migration to squash.")
+	squashCmd.Flags().StringVar(&squashAction.Dir, "dir", "", "Directory on disk that contains the migrations.")
+	squashCmd.Flags().StringVar(&squashAction.ArchiveDir, "archive", "", "Directory to move the squashed migrations to (default DIR/archive).")
+	squashCmd.MarkFlagRequired("upto")
+	squashCmd.MarkFlagRequired("dir")
+
 	// Seeding
 	seedCmd := &cobra.Command{
 		Use:     "seed [SEED]",
@@ -233,6 +477,41 @@ Rolls back all migrations that were applied to the database.
 		},
 	}
 
+	// Initialization
+	var initPlan, ignoreConditions, singleTransaction bool
+	initCmd := &cobra.Command{
+		Use:     "init [NAME]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   "Runs the init scripts on an existing database",
+		GroupID: "init",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if ignoreConditions {
+				if err := cli.Config.Extend(psqlmanager.WithIgnoreInitConditions(true)); err != nil {
+					return err
+				}
+			}
+
+			if singleTransaction {
+				if err := cli.Config.Extend(psqlmanager.WithInitTransactionMode(psqlinit.SINGLE_TRANSACTION)); err != nil {
+					return err
+				}
+			}
+
+			var database *db.Database
+			if len(args) > 0 {
+				database = &db.Database{Name: args[0]}
+			}
+
+			if initPlan {
+				return psqlmanager.PlanInit(cmd.Context(), database, cli.Config)
+			}
+			return psqlmanager.RunInit(cmd.Context(), database, cli.Config)
+		},
+	}
+	initCmd.Flags().BoolVar(&initPlan, "plan", initPlan, "Evaluate all conditions and show which init scripts would be applied, without applying them.")
+	initCmd.Flags().BoolVar(&ignoreConditions, "ignore-conditions", ignoreConditions, "Apply all init scripts, regardless of their skip conditions.")
+	initCmd.Flags().BoolVar(&singleTransaction, "single-transaction", singleTransaction, "Apply all init scripts in a single transaction. Rolls back all of them if one fails.")
+
 	// Temporary databases commands
 	handleSeedFlag := func(cmd *cobra.Command, args []string) {
 		if cli.flags.seed.enable {
@@ -247,6 +526,9 @@ Rolls back all migrations that were applied to the database.
 		GroupID:          "temp",
 		PersistentPreRun: handleSeedFlag,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyAllowMissing(); err != nil {
+				return err
+			}
 
 			var migrateAction psqlmigrate.MigrateAction = psqlmigrate.UpToLatestAction
 			database := cli.Config.TargetDatabase()
@@ -270,6 +552,7 @@ Rolls back all migrations that were applied to the database.
 		},
 	}
 	addSeedFlag(createCmd.Flags(), &cli.flags.seed)
+	addAllowMissingFlag(createCmd)
 
 	dropCmd := &cobra.Command{
 		Use:     "drop [NAME]",
@@ -299,6 +582,9 @@ Rolls back all migrations that were applied to the database.
 		GroupID:          "temp",
 		PersistentPreRun: handleSeedFlag,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyAllowMissing(); err != nil {
+				return err
+			}
 
 			var migrateAction psqlmigrate.MigrateAction = psqlmigrate.UpToLatestAction
 			database := cli.Config.TargetDatabase()
@@ -322,6 +608,7 @@ Rolls back all migrations that were applied to the database.
 		},
 	}
 	addSeedFlag(freshCmd.Flags(), &cli.flags.seed)
+	addAllowMissingFlag(freshCmd)
 	cli.AddExecCmd()
 
 	// Add commands to root command
@@ -333,8 +620,13 @@ Rolls back all migrations that were applied to the database.
 		statusCmd,
 		migrateCmd,
 		migrationsCmd,
+		driftCmd,
+		schemaCmd,
+		squashCmd,
+		baselineCmd,
 		seedCmd,
 		seedersCmd,
+		initCmd,
 		createCmd,
 		dropCmd,
 		freshCmd,
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	psqlmanager "github.com/shared-digitaltechnologies/psql-manager"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
 	"github.com/shared-digitaltechnologies/psql-manager/seed/fake"
 	"github.com/spf13/pflag"
 )
@@ -21,8 +25,24 @@ func addCliFlags(flags *pflag.FlagSet, target *cliFlags) {
 	flags.BoolVarP(&target.verbose, "verbose", "v", target.verbose, "Enable verbose logs")
 }
 
+func (flags *cliFlags) verbosity() psqlmanager.LogVerbosity {
+	switch {
+	case flags.quiet:
+		return psqlmanager.LOG_QUIET
+	case flags.verbose:
+		return psqlmanager.LOG_VERBOSE
+	default:
+		return psqlmanager.LOG_DEFAULT
+	}
+}
+
+// applyToConfig replaces the logger of the config with a console logger,
+// unless the config has a logger and the flags are not set.
 func (flags *cliFlags) applyToConfig(c *psqlmanager.Config) error {
-	return nil
+	if c.Logger != nil && flags.color == psqlmanager.COLOR_AUTO && flags.verbosity() == psqlmanager.LOG_DEFAULT {
+		return nil
+	}
+	return c.Extend(psqlmanager.WithConsoleLogger(flags.color, flags.verbosity()))
 }
 
 type connectFlags struct {
@@ -50,6 +70,208 @@ func addConnectFlags(flags *pflag.FlagSet, target *connectFlags, config *psqlman
 	flags.StringVarP(&target.dbname, "database", "d", config.TargetDatabase().Name, "Name of the target database")
 }
 
+type lockFlags struct {
+	noLock  bool
+	id      int64
+	timeout time.Duration
+}
+
+func addLockFlags(flags *pflag.FlagSet, target *lockFlags, config *psqlmanager.Config) {
+	lock := config.MigrationLock()
+	if lock == nil {
+		target.noLock = true
+		lock = psqlmigrate.DefaultAdvisoryLock()
+	}
+	target.id = lock.Id
+	target.timeout = lock.WaitTimeout
+
+	flags.BoolVar(&target.noLock, "no-lock", target.noLock, "Do not take the advisory migration lock.")
+	flags.Int64Var(&target.id, "lock-id", target.id, "Id of the advisory migration lock.")
+	flags.DurationVar(&target.timeout, "migration-lock-wait", target.timeout, "How long to wait for the advisory migration lock.")
+}
+
+func (flags *lockFlags) applyToConfig(c *psqlmanager.Config) error {
+	if flags.noLock {
+		return c.Extend(psqlmanager.WithMigrationLock(nil))
+	}
+
+	return c.Extend(
+		psqlmanager.WithMigrationLockId(flags.id),
+		psqlmanager.WithMigrationLockWait(flags.timeout),
+	)
+}
+
+type migrationSetFlags struct {
+	sets []string
+}
+
+func addMigrationSetFlags(flags *pflag.FlagSet, target *migrationSetFlags) {
+	flags.StringSliceVar(&target.sets, "set", target.sets, "Only use the migration sets with these names.")
+}
+
+func (flags *migrationSetFlags) applyToConfig(c *psqlmanager.Config) error {
+	if len(flags.sets) == 0 {
+		return nil
+	}
+	return c.Extend(psqlmanager.WithOnlyMigrationSets(flags.sets...))
+}
+
+type databasesFlags struct {
+	pattern       string
+	schemaPattern string
+	schemaQuery   string
+	concurrency   int
+	failFast      bool
+}
+
+func addDatabasesFlags(flags *pflag.FlagSet, target *databasesFlags) {
+	if target.concurrency == 0 {
+		target.concurrency = 1
+	}
+
+	flags.StringVar(&target.pattern, "all-databases", target.pattern, "Run on all databases matching the glob or /regex/ PATTERN.")
+	flags.StringVar(&target.schemaPattern, "per-schema", target.schemaPattern, "Run on each tenant schema matching the glob or /regex/ PATTERN.")
+	flags.StringVar(&target.schemaQuery, "schema-query", target.schemaQuery, "Run on each tenant schema returned by the SQL query.")
+	flags.IntVar(&target.concurrency, "concurrency", target.concurrency, "Number of databases or schemas to run on at the same time.")
+	flags.BoolVar(&target.failFast, "fail-fast", target.failFast, "Stop after the first failed database or schema instead of continuing.")
+}
+
+func (flags *databasesFlags) enabled() bool {
+	return len(flags.pattern) > 0
+}
+
+func (flags *databasesFlags) perSchema() bool {
+	return len(flags.schemaPattern) > 0 || len(flags.schemaQuery) > 0
+}
+
+func (flags *databasesFlags) validate() error {
+	if flags.enabled() && flags.perSchema() {
+		return fmt.Errorf("--all-databases cannot be combined with --per-schema or --schema-query")
+	}
+	return nil
+}
+
+func (flags *databasesFlags) schemasOpts() *psqlmanager.SchemasOpts {
+	return &psqlmanager.SchemasOpts{
+		Pattern:     flags.schemaPattern,
+		Query:       flags.schemaQuery,
+		Concurrency: flags.concurrency,
+		FailFast:    flags.failFast,
+	}
+}
+
+func (flags *databasesFlags) opts() *psqlmanager.DatabasesOpts {
+	return &psqlmanager.DatabasesOpts{
+		Pattern:     flags.pattern,
+		Concurrency: flags.concurrency,
+		FailFast:    flags.failFast,
+	}
+}
+
+type sqlVarsFlags struct {
+	vars   map[string]string
+	strict bool
+}
+
+func addSqlVarsFlags(flags *pflag.FlagSet, target *sqlVarsFlags) {
+	flags.StringToStringVar(&target.vars, "var", target.vars, "Substitute ${KEY} placeholders in the SQL with VAL [KEY=VAL].")
+	flags.BoolVar(&target.strict, "strict-vars", target.strict, "Fail on ${KEY} placeholders without a value.")
+}
+
+func (flags *sqlVarsFlags) applyToConfig(c *psqlmanager.Config) error {
+	if len(flags.vars) > 0 {
+		if err := c.Extend(psqlmanager.WithSqlVars(flags.vars)); err != nil {
+			return err
+		}
+	}
+	if flags.strict {
+		return c.Extend(psqlmanager.WithStrictSqlVars(true))
+	}
+	return nil
+}
+
+type timeoutFlags struct {
+	migrate db.SessionTimeouts
+	init    db.SessionTimeouts
+	seed    db.SessionTimeouts
+	retries int
+	backoff time.Duration
+}
+
+func addTimeoutFlags(flags *pflag.FlagSet, target *timeoutFlags) {
+	target.retries = 1
+	target.backoff = time.Second
+
+	flags.Var((*sessionTimeoutsOpt)(&target.migrate), "migrate-timeouts", "Session timeouts of the migrations [lock=5s,statement=1m,idle=30s].")
+	flags.Var((*sessionTimeoutsOpt)(&target.init), "init-timeouts", "Session timeouts of the init scripts [lock=5s,statement=1m,idle=30s].")
+	flags.Var((*sessionTimeoutsOpt)(&target.seed), "seed-timeouts", "Session timeouts of the seeders [lock=5s,statement=1m,idle=30s].")
+	flags.IntVar(&target.retries, "lock-timeout-retries", target.retries, "Attempts per migration when it fails on a Postgres lock_timeout.")
+	flags.DurationVar(&target.backoff, "lock-timeout-backoff", target.backoff, "Wait time before retrying a migration. Doubles after each retry.")
+}
+
+func (flags *timeoutFlags) applyToConfig(c *psqlmanager.Config) error {
+	var options []psqlmanager.ConfigOption
+	if flags.retries != 1 {
+		options = append(options, psqlmanager.WithLockTimeoutRetry(flags.retries, flags.backoff))
+	}
+	if !flags.migrate.IsZero() {
+		options = append(options, psqlmanager.WithMigrationTimeouts(flags.migrate))
+	}
+	if !flags.init.IsZero() {
+		options = append(options, psqlmanager.WithInitTimeouts(flags.init))
+	}
+	if !flags.seed.IsZero() {
+		options = append(options, psqlmanager.WithSeedTimeouts(flags.seed))
+	}
+	return c.Extend(options...)
+}
+
+type sessionTimeoutsOpt db.SessionTimeouts
+
+func (o *sessionTimeoutsOpt) Set(val string) error {
+	for _, part := range strings.Split(val, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return fmt.Errorf("Invalid timeout \"%s\": expected NAME=DURATION", part)
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("Invalid timeout \"%s\": %w", part, err)
+		}
+
+		switch name {
+		case "lock":
+			o.LockTimeout = d
+		case "statement":
+			o.StatementTimeout = d
+		case "idle":
+			o.IdleInTransactionSessionTimeout = d
+		default:
+			return fmt.Errorf("Unknown timeout \"%s\": expected lock, statement or idle", name)
+		}
+	}
+	return nil
+}
+
+func (o *sessionTimeoutsOpt) String() string {
+	var parts []string
+	if o.LockTimeout > 0 {
+		parts = append(parts, "lock="+o.LockTimeout.String())
+	}
+	if o.StatementTimeout > 0 {
+		parts = append(parts, "statement="+o.StatementTimeout.String())
+	}
+	if o.IdleInTransactionSessionTimeout > 0 {
+		parts = append(parts, "idle="+o.IdleInTransactionSessionTimeout.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (o *sessionTimeoutsOpt) Type() string {
+	return "timeouts"
+}
+
 func execActionFlags(flags *pflag.FlagSet, target *psqlmanager.ExecActionOpts) {
 	flags.BoolVar(&target.Keep, "keep", target.Keep, "Do not drop the temporary database afterwards.")
 	flags.BoolVar(&target.KeepAfterSuccess, "keep-after-success", target.KeepAfterSuccess, "Do not drop temp database if exit code is 0.")
@@ -59,6 +281,31 @@ func execActionFlags(flags *pflag.FlagSet, target *psqlmanager.ExecActionOpts) {
 	flags.BoolVar(&target.NoInheritEnv, "no-inherit-env", target.NoInheritEnv, "Do not inherit the env-variables of this command.")
 }
 
+type schemaFlags struct {
+	schemas        []string
+	excludeSchemas []string
+	kinds          []string
+}
+
+func addSchemaFlags(flags *pflag.FlagSet, target *schemaFlags) {
+	flags.StringSliceVar(&target.schemas, "schema", target.schemas, "Only include objects in these schemas.")
+	flags.StringSliceVar(&target.excludeSchemas, "exclude-schema", target.excludeSchemas, "Exclude objects in these schemas.")
+	flags.StringSliceVar(&target.kinds, "kind", target.kinds, "Only include objects of these kinds.")
+}
+
+func (flags *schemaFlags) schemaOptions() (opts db.SchemaOptions, err error) {
+	opts.Schemas = flags.schemas
+	opts.ExcludeSchemas = flags.excludeSchemas
+	for _, k := range flags.kinds {
+		kind, err := db.ParseSchemaObjectKind(k)
+		if err != nil {
+			return opts, err
+		}
+		opts.Kinds = append(opts.Kinds, kind)
+	}
+	return opts, nil
+}
+
 func addSeedFlag(flags *pflag.FlagSet, target *seedOpt) {
 	flags.VarP(target, "seed", "s", "Also seed the database.")
 	flag := flags.Lookup("seed")
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+func TestSessionTimeoutsOpt(t *testing.T) {
+	var opt sessionTimeoutsOpt
+	if err := opt.Set("lock=5s, statement=1m,idle=30s"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := db.SessionTimeouts{
+		LockTimeout:                     5 * time.Second,
+		StatementTimeout:                time.Minute,
+		IdleInTransactionSessionTimeout: 30 * time.Second,
+	}
+	if db.SessionTimeouts(opt) != expected {
+		t.Errorf("expected %+v, got %+v", expected, opt)
+	}
+	if s := opt.String(); s != "lock=5s,statement=1m0s,idle=30s" {
+		t.Errorf("unexpected string %q", s)
+	}
+
+	var partial sessionTimeoutsOpt
+	if err := partial.Set("statement=2s"); err != nil {
+		t.Fatal(err)
+	}
+	if s := partial.String(); s != "statement=2s" {
+		t.Errorf("expected only the statement timeout, got %q", s)
+	}
+}
+
+func TestSessionTimeoutsOptErrors(t *testing.T) {
+	for _, val := range []string{"lock", "lock=5", "lock=abc", "wait=5s", "lock=5s,"} {
+		var opt sessionTimeoutsOpt
+		if err := opt.Set(val); err == nil {
+			t.Errorf("expected an error for %q", val)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	psqlmanager "github.com/shared-digitaltechnologies/psql-manager"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type outputFormat string
+
+const (
+	FORMAT_TABLE outputFormat = "table"
+	FORMAT_JSON  outputFormat = "json"
+	FORMAT_YAML  outputFormat = "yaml"
+)
+
+func (f *outputFormat) String() string {
+	if len(*f) == 0 {
+		return string(FORMAT_TABLE)
+	}
+	return string(*f)
+}
+
+func (f *outputFormat) Set(val string) error {
+	switch outputFormat(strings.ToLower(val)) {
+	case FORMAT_TABLE:
+		*f = FORMAT_TABLE
+	case FORMAT_JSON:
+		*f = FORMAT_JSON
+	case FORMAT_YAML:
+		*f = FORMAT_YAML
+	default:
+		return fmt.Errorf("Invalid format '%s'. Valid formats are 'table', 'json' or 'yaml'", val)
+	}
+	return nil
+}
+
+func (f *outputFormat) Type() string {
+	return "format"
+}
+
+// writeFormatted writes the value as json or yaml. Returns false if the
+// format is the table format, which has to be written by the caller.
+func writeFormatted(w io.Writer, format outputFormat, value any) (bool, error) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return true, enc.Encode(value)
+	case FORMAT_YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(value); err != nil {
+			return true, err
+		}
+		return true, enc.Close()
+	default:
+		return false, nil
+	}
+}
+
+func writeStatusTable(w io.Writer, report *psqlmigrate.StatusReport) {
+	for _, m := range report.Migrations {
+		var state string
+		switch m.State {
+		case psqlmigrate.STATE_APPLIED:
+			state = "APPLIED AT " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			if m.OutOfOrder {
+				state += " (OUT OF ORDER)"
+			}
+			if m.Modified {
+				state += " (MODIFIED)"
+			}
+		default:
+			state = strings.ToUpper(string(m.State))
+		}
+
+		fmt.Fprintf(w, "%05d %-3s %-60s %s\n", m.Version, m.Type, m.Path, state)
+	}
+
+	for _, version := range report.Orphaned {
+		fmt.Fprintf(w, "%05d %-3s %-60s %s\n", version, "", "", "ORPHANED (NOT FOUND IN MIGRATIONS)")
+	}
+
+	if missing := report.MissingVersions(); len(missing) > 0 {
+		fmt.Fprintf(w, ">> %d MISSING migrations older than version %d: %s (apply with --allow-missing)\n",
+			len(missing),
+			report.Version,
+			joinVersions(missing),
+		)
+	}
+	if len(report.Orphaned) > 0 {
+		fmt.Fprintf(w, ">> %d ORPHANED versions in the version table: %s\n",
+			len(report.Orphaned),
+			joinVersions(report.Orphaned),
+		)
+	}
+}
+
+func joinVersions(versions []int64) string {
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = fmt.Sprintf("%05d", v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func writeDatabaseResults(w io.Writer, results []*psqlmanager.DatabaseResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, ">> DATABASES\n")
+	for _, r := range results {
+		fmt.Fprintf(w, "    %s\n", r)
+	}
+}
+
+func writeStatusTables(w io.Writer, reports []*psqlmigrate.StatusReport) {
+	for i, report := range reports {
+		if len(report.Set) > 0 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, ">> MIGRATION SET \"%s\"\n", report.Set)
+		}
+		writeStatusTable(w, report)
+	}
+}
+
+func checkStatusReports(reports []*psqlmigrate.StatusReport) error {
+	var errs []error
+	for _, report := range reports {
+		if err := report.Check(); err != nil {
+			if len(report.Set) > 0 {
+				err = fmt.Errorf("Migration set \"%s\": %w", report.Set, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func writeDatabasesStatus(cmd *cobra.Command, format outputFormat, check bool, flags *databasesFlags, config *psqlmanager.Config) error {
+	var reports []*psqlmanager.DatabaseStatusReport
+	var err error
+	if flags.perSchema() {
+		reports, err = psqlmanager.MigrationStatusReportPerSchema(cmd.Context(), nil, flags.schemasOpts(), config)
+	} else {
+		reports, err = psqlmanager.MigrationStatusReportInDatabases(cmd.Context(), flags.opts(), config)
+	}
+	if reports == nil {
+		return err
+	}
+
+	written, writeErr := writeFormatted(os.Stdout, format, reports)
+	if writeErr != nil {
+		return writeErr
+	}
+	if !written && flags.perSchema() {
+		// There are usually many tenant schemas, so write a summary line per
+		// schema instead of the full tables.
+		for _, report := range reports {
+			writeStatusSummary(os.Stdout, report)
+		}
+	} else if !written {
+		for i, report := range reports {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf(">> DATABASE \"%s\"\n", report.Database)
+			if report.Err != nil {
+				fmt.Printf("    FAILED: %s\n", report.Err)
+				continue
+			}
+			writeStatusTables(os.Stdout, report.Reports)
+		}
+	}
+
+	if !check {
+		return err
+	}
+
+	errs := []error{err}
+	for _, report := range reports {
+		if checkErr := checkStatusReports(report.Reports); checkErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", report.Target(), checkErr))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeStatusSummary writes a single line per migration set with the version
+// of the schema.
+func writeStatusSummary(w io.Writer, report *psqlmanager.DatabaseStatusReport) {
+	if report.Err != nil {
+		fmt.Fprintf(w, "%-40s FAILED: %s\n", report.Target(), report.Err)
+		return
+	}
+
+	for _, r := range report.Reports {
+		target := report.Target()
+		if len(r.Set) > 0 {
+			target += " [" + r.Set + "]"
+		}
+
+		var details []string
+		if n := r.Count(psqlmigrate.STATE_PENDING); n > 0 {
+			details = append(details, fmt.Sprintf("%d pending", n))
+		}
+		if n := r.Count(psqlmigrate.STATE_MISSING); n > 0 {
+			details = append(details, fmt.Sprintf("%d missing", n))
+		}
+		if n := r.CountModified(); n > 0 {
+			details = append(details, fmt.Sprintf("%d modified", n))
+		}
+		if len(details) == 0 {
+			details = append(details, "up to date")
+		}
+
+		fmt.Fprintf(w, "%-40s %05d of %05d %s\n", target, r.Version, r.LatestVersion, strings.Join(details, ", "))
+	}
+}
@@ -3,11 +3,13 @@ package psqlmanager
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/shared-digitaltechnologies/psql-manager/db"
@@ -21,13 +23,23 @@ type Config struct {
 
 	DatabaseName string
 
-	InitRunner                psqlinit.Runner
-	ownsCurrentInitRepository bool
+	InitRunner                       psqlinit.Runner
+	ownsCurrentInitRepository        bool
+	ownsCurrentClusterInitRepository bool
 
 	SeederRunner                psqlseed.Runner
 	ownsCurrentSeederRepository bool
 
 	migrationProviderFactory *psqlmigrate.ProviderFactory
+	migrationSets            []psqlmigrate.MigrationSet
+	onlyMigrationSets        []string
+
+	// Substitution of `${NAME}` placeholders in SQL. Disabled if nil.
+	sqlVars *sqlVarsConfig
+
+	// Logs the reports of all phases. Logs to stdout with a ConsoleHandler if
+	// nil. See WithLogger and WithConsoleLogger.
+	Logger *slog.Logger
 }
 
 var GlobalConfig Config
@@ -48,12 +60,20 @@ func (c *Config) Copy() *Config {
 	if c.migrationProviderFactory != nil {
 		res.migrationProviderFactory = c.migrationProviderFactory.Copy()
 	}
+	res.migrationSets = slices.Clone(c.migrationSets)
+	res.onlyMigrationSets = slices.Clone(c.onlyMigrationSets)
+	res.sqlVars = c.sqlVars.copy()
 
 	if c.ownsCurrentInitRepository {
 		res.InitRunner.Repository = c.InitRunner.Repository.Copy()
 		res.ownsCurrentInitRepository = true
 	}
 
+	if c.ownsCurrentClusterInitRepository {
+		res.InitRunner.ClusterRepository = c.InitRunner.Cluster().Copy()
+		res.ownsCurrentClusterInitRepository = true
+	}
+
 	if c.ownsCurrentSeederRepository {
 		res.SeederRunner.Repository = c.SeederRunner.Repository.Copy()
 		res.ownsCurrentSeederRepository = true
@@ -94,6 +114,16 @@ func (c *Config) TargetDatabase() *db.Database {
 	return &db.Database{Name: name}
 }
 
+// MigrationLock returns the advisory lock that serializes migrate actions,
+// or nil if locking is disabled.
+func (c *Config) MigrationLock() *psqlmigrate.AdvisoryLock {
+	if c == nil {
+		c = &GlobalConfig
+	}
+
+	return c.migrationProviderFactory.MigrationLock()
+}
+
 type ConnStringType int8
 
 const (
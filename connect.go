@@ -96,5 +96,10 @@ func OpenGooseProvider(ctx context.Context, config *Config) (*goose.Provider, er
 		return nil, err
 	}
 
-	return config.migrationProviderFactory.OpenProvider(ctx, connConfig)
+	factory, err := config.singleMigrationSetFactory()
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.OpenProvider(ctx, connConfig)
 }
@@ -0,0 +1,244 @@
+package psqlmanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+)
+
+// DatabasesOpts determine on which databases an action runs, and how.
+type DatabasesOpts struct {
+	// Glob pattern, or regular expression wrapped in slashes, that is
+	// matched against the names of the databases.
+	Pattern string
+
+	// Maximum number of databases on which the action runs at the same
+	// time. Runs on one database at a time if less than 1.
+	Concurrency int
+
+	// Do not start on any more databases after the action failed on one.
+	FailFast bool
+}
+
+// DatabaseResult is the result of an action on a single database, or on a
+// single schema in the database.
+type DatabaseResult struct {
+	Database string        `json:"database" yaml:"database"`
+	Schema   string        `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Duration time.Duration `json:"-" yaml:"-"`
+	// Set if the action did not start because of an earlier failure.
+	Skipped bool   `json:"skipped" yaml:"skipped"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+	Err     error  `json:"-" yaml:"-"`
+}
+
+// Target returns the name of the database, qualified with the schema if set.
+func (r *DatabaseResult) Target() string {
+	if len(r.Schema) > 0 {
+		return r.Database + "." + r.Schema
+	}
+	return r.Database
+}
+
+func (r *DatabaseResult) String() string {
+	switch {
+	case r.Skipped:
+		return fmt.Sprintf("SKIPPED %s", r.Target())
+	case r.Err != nil:
+		return fmt.Sprintf("FAILED  %s (%s): %s", r.Target(), r.Duration.Round(time.Millisecond), r.Err)
+	default:
+		return fmt.Sprintf("OK      %s (%s)", r.Target(), r.Duration.Round(time.Millisecond))
+	}
+}
+
+// DatabasesError is returned if an action failed on some of the databases
+// or schemas.
+type DatabasesError struct {
+	Results []*DatabaseResult
+}
+
+func (e *DatabasesError) Failed() []*DatabaseResult {
+	var res []*DatabaseResult
+	for _, r := range e.Results {
+		if r.Err != nil {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func (e *DatabasesError) Error() string {
+	target := "databases"
+	if len(e.Results) > 0 && len(e.Results[0].Schema) > 0 {
+		target = "schemas"
+	}
+
+	res := fmt.Sprintf("Failed on %d of %d %s", len(e.Failed()), len(e.Results), target)
+	for _, err := range e.Unwrap() {
+		res += "\n" + err.Error()
+	}
+	return res
+}
+
+func (e *DatabasesError) Unwrap() []error {
+	var res []error
+	for _, r := range e.Failed() {
+		if len(r.Schema) > 0 {
+			res = append(res, fmt.Errorf("Schema \"%s\": %w", r.Target(), r.Err))
+		} else {
+			res = append(res, fmt.Errorf("Database \"%s\": %w", r.Database, r.Err))
+		}
+	}
+	return res
+}
+
+// MatchingDatabases returns the databases on the server whose names match
+// the pattern.
+func MatchingDatabases(ctx context.Context, pattern string, config *Config) ([]*db.Database, error) {
+	p, err := db.ParseNamePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	rootConn, err := ConnectRootDB(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer rootConn.Close(ctx)
+
+	return db.MatchingDatabases(ctx, rootConn, p)
+}
+
+// forEachDatabase runs fn on each database, on at most opts.Concurrency
+// databases at the same time. The results are in the order of the databases.
+func forEachDatabase(ctx context.Context, databases []*db.Database, opts *DatabasesOpts, fn func(ctx context.Context, i int, database *db.Database) error) []*DatabaseResult {
+	results := make([]*DatabaseResult, len(databases))
+	for i, database := range databases {
+		results[i] = &DatabaseResult{Database: database.Name}
+	}
+
+	runConcurrently(ctx, results, opts.Concurrency, opts.FailFast, func(ctx context.Context, i int) error {
+		return fn(ctx, i, databases[i])
+	})
+	return results
+}
+
+// runConcurrently runs fn for each result, on at most concurrency results at
+// the same time, and stores the outcome in the result. Marks the remaining
+// results as skipped after the first error if failFast is set.
+func runConcurrently(ctx context.Context, results []*DatabaseResult, concurrency int, failFast bool, fn func(ctx context.Context, i int) error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, res := range results {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			res.Skipped = true
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, res *DatabaseResult) {
+			defer func() { <-sem; wg.Done() }()
+
+			start := time.Now()
+			res.Err = fn(ctx, i)
+			res.Duration = time.Since(start)
+
+			if res.Err != nil {
+				res.Error = res.Err.Error()
+				if failFast {
+					cancel()
+				}
+			}
+		}(i, res)
+	}
+
+	wg.Wait()
+}
+
+// databasesError returns a DatabasesError if the action failed on any of
+// the databases, or the error of the context if it was cancelled.
+func databasesError(ctx context.Context, results []*DatabaseResult) error {
+	for _, r := range results {
+		if r.Err != nil {
+			return &DatabasesError{Results: results}
+		}
+	}
+	return ctx.Err()
+}
+
+// RunMigrateActionInDatabases runs the migrate action on all databases that
+// match the pattern. Returns a DatabasesError if it failed on any of them.
+//
+// The logs of the databases interleave if the concurrency is larger than 1.
+func RunMigrateActionInDatabases(ctx context.Context, action psqlmigrate.MigrateAction, opts *DatabasesOpts, config *Config) ([]*DatabaseResult, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+
+	databases, err := MatchingDatabases(ctx, opts.Pattern, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("No databases match \"%s\"", opts.Pattern)
+	}
+
+	config.logger().Info(fmt.Sprintf("%s on %d databases matching \"%s\"", action, len(databases), opts.Pattern), db.Phase("migrate"))
+
+	results := forEachDatabase(ctx, databases, opts, func(ctx context.Context, _ int, database *db.Database) error {
+		config.logger().Info(fmt.Sprintf("DATABASE \"%s\"", database.Name), db.Phase("migrate"), "database", database.Name)
+		return runMigrateActionInDatabase(ctx, action, database, config, true)
+	})
+
+	return results, databasesError(ctx, results)
+}
+
+// DatabaseStatusReport holds the status reports of the migration sets of a
+// single database.
+type DatabaseStatusReport struct {
+	DatabaseResult `yaml:",inline"`
+	Reports        []*psqlmigrate.StatusReport `json:"reports" yaml:"reports"`
+}
+
+// MigrationStatusReportInDatabases reads the migration status of all
+// databases that match the pattern. Returns a DatabasesError if it failed to
+// read the status of any of them.
+func MigrationStatusReportInDatabases(ctx context.Context, opts *DatabasesOpts, config *Config) ([]*DatabaseStatusReport, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+
+	databases, err := MatchingDatabases(ctx, opts.Pattern, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("No databases match \"%s\"", opts.Pattern)
+	}
+
+	reports := make([]*DatabaseStatusReport, len(databases))
+	results := forEachDatabase(ctx, databases, opts, func(ctx context.Context, i int, database *db.Database) (err error) {
+		reports[i] = &DatabaseStatusReport{}
+		reports[i].Reports, err = MigrationStatusReportInDatabase(ctx, database, config)
+		return err
+	})
+
+	for i, result := range results {
+		if reports[i] == nil {
+			reports[i] = &DatabaseStatusReport{}
+		}
+		reports[i].DatabaseResult = *result
+	}
+
+	return reports, databasesError(ctx, results)
+}
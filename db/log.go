@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of the context that carries the logger. Runners
+// pass their logger to the scripts, seeders and hooks that they run this way.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger of the context, or slog.Default() if the
+// context has none.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Keys of the attributes with which the runners describe how their log
+// records are presented. The ConsoleHandler of psqlmanager renders them,
+// other handlers log them as regular attributes.
+const (
+	// Name of the phase of which the record is the header.
+	PhaseKey = "phase"
+	// Nesting level of the record below the header of its phase.
+	IndentKey = "indent"
+	// Status of the step that the record reports, like OK or SKIPPED.
+	StatusKey = "status"
+	// Duration of the step that the record reports.
+	DurationKey = "duration"
+)
+
+// Phase marks the record as the header of the phase.
+func Phase(name string) slog.Attr {
+	return slog.String(PhaseKey, name)
+}
+
+// Indent nests the record level levels below the header of its phase.
+func Indent(level int) slog.Attr {
+	return slog.Int(IndentKey, level)
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// NamePattern matches database or schema names. It is either a glob
+// pattern, or a regular expression if it is wrapped in slashes, like
+// `/^tenant_\d+$/`.
+type NamePattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+func ParseNamePattern(pattern string) (*NamePattern, error) {
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("Empty pattern")
+	}
+
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid name pattern \"%s\": %w", pattern, err)
+		}
+		return &NamePattern{regex: regex}, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Invalid name pattern \"%s\": %w", pattern, err)
+	}
+	return &NamePattern{glob: pattern}, nil
+}
+
+func (p *NamePattern) Match(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	ok, _ := path.Match(p.glob, name)
+	return ok
+}
+
+func (p *NamePattern) String() string {
+	if p.regex != nil {
+		return "/" + p.regex.String() + "/"
+	}
+	return p.glob
+}
+
+// MatchingDatabases returns the databases that accept connections and whose
+// names match the pattern, ordered by name. Template databases are skipped.
+func MatchingDatabases(ctx context.Context, conn conn, pattern *NamePattern) ([]*Database, error) {
+	var names []string
+	err := conn.QueryRow(ctx,
+		"SELECT coalesce(array_agg(datname ORDER BY datname), '{}') FROM pg_catalog.pg_database WHERE datallowconn AND NOT datistemplate",
+	).Scan(&names)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list databases: %w", err)
+	}
+
+	var res []*Database
+	for _, name := range names {
+		if pattern.Match(name) {
+			res = append(res, &Database{Name: name})
+		}
+	}
+	return res, nil
+}
+
+// MatchingSchemas returns the schemas in the database whose names match the
+// pattern, ordered by name. System schemas are skipped.
+func MatchingSchemas(ctx context.Context, conn conn, pattern *NamePattern) ([]string, error) {
+	var names []string
+	err := conn.QueryRow(ctx,
+		`SELECT coalesce(array_agg(nspname ORDER BY nspname), '{}') FROM pg_catalog.pg_namespace
+WHERE nspname NOT IN ('pg_catalog', 'information_schema') AND nspname NOT LIKE 'pg\_%'`,
+	).Scan(&names)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list schemas: %w", err)
+	}
+
+	var res []string
+	for _, name := range names {
+		if pattern.Match(name) {
+			res = append(res, name)
+		}
+	}
+	return res, nil
+}
+
+// QuerySchemas returns the schema names in the first column of the rows
+// returned by the query.
+func QuerySchemas(ctx context.Context, conn *pgx.Conn, query string) ([]string, error) {
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query schemas: %w", err)
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query schemas: %w", err)
+	}
+	return names, nil
+}
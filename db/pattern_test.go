@@ -0,0 +1,36 @@
+package db
+
+import "testing"
+
+func TestNamePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"tenant_*", "tenant_1", true},
+		{"tenant_*", "app", false},
+		{"tenant_?", "tenant_12", false},
+		{"/^tenant_\\d+$/", "tenant_12", true},
+		{"/^tenant_\\d+$/", "tenant_x", false},
+		{"/tenant/", "old_tenant_1", true},
+	}
+
+	for _, test := range tests {
+		pattern, err := ParseNamePattern(test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pattern.Match(test.name); got != test.match {
+			t.Errorf("%s matches %s: got %v, expected %v", test.pattern, test.name, got, test.match)
+		}
+	}
+}
+
+func TestInvalidNamePattern(t *testing.T) {
+	for _, pattern := range []string{"", "/(/", "[a"} {
+		if _, err := ParseNamePattern(pattern); err == nil {
+			t.Errorf("Expected error for pattern %q", pattern)
+		}
+	}
+}
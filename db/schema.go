@@ -0,0 +1,497 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type queryer interface {
+	Query(ctx context.Context, sql string, arguments ...any) (pgx.Rows, error)
+}
+
+type SchemaObjectKind string
+
+const (
+	OBJ_SCHEMA            SchemaObjectKind = "schema"
+	OBJ_EXTENSION         SchemaObjectKind = "extension"
+	OBJ_TYPE              SchemaObjectKind = "type"
+	OBJ_DOMAIN            SchemaObjectKind = "domain"
+	OBJ_SEQUENCE          SchemaObjectKind = "sequence"
+	OBJ_FUNCTION          SchemaObjectKind = "function"
+	OBJ_TABLE             SchemaObjectKind = "table"
+	OBJ_COLUMN            SchemaObjectKind = "column"
+	OBJ_CONSTRAINT        SchemaObjectKind = "constraint"
+	OBJ_INDEX             SchemaObjectKind = "index"
+	OBJ_VIEW              SchemaObjectKind = "view"
+	OBJ_MATERIALIZED_VIEW SchemaObjectKind = "materialized_view"
+	OBJ_TRIGGER           SchemaObjectKind = "trigger"
+	OBJ_GRANT             SchemaObjectKind = "grant"
+)
+
+// SchemaObjectKinds lists all object kinds in the order in which they
+// are inspected.
+var SchemaObjectKinds = []SchemaObjectKind{
+	OBJ_SCHEMA,
+	OBJ_EXTENSION,
+	OBJ_TYPE,
+	OBJ_DOMAIN,
+	OBJ_SEQUENCE,
+	OBJ_FUNCTION,
+	OBJ_TABLE,
+	OBJ_COLUMN,
+	OBJ_CONSTRAINT,
+	OBJ_INDEX,
+	OBJ_VIEW,
+	OBJ_MATERIALIZED_VIEW,
+	OBJ_TRIGGER,
+	OBJ_GRANT,
+}
+
+func ParseSchemaObjectKind(val string) (SchemaObjectKind, error) {
+	kind := SchemaObjectKind(strings.ToLower(strings.ReplaceAll(val, "-", "_")))
+	if !slices.Contains(SchemaObjectKinds, kind) {
+		return kind, fmt.Errorf("Unknown schema object kind '%s'", val)
+	}
+	return kind, nil
+}
+
+// SchemaObject is a single object in the database catalog.
+//
+// Parent holds the (unqualified) name of the relation the object belongs to
+// for columns, constraints and triggers. For grants, it holds the privilege
+// target prefixed by its type, like "TABLE users" or "SCHEMA app", and Name
+// holds the grantee.
+type SchemaObject struct {
+	Kind       SchemaObjectKind
+	Schema     string
+	Parent     string
+	Name       string
+	Definition string
+	Position   int
+}
+
+func (o *SchemaObject) Key() string {
+	if len(o.Parent) > 0 {
+		return string(o.Kind) + " " + o.Schema + "." + o.Parent + "." + o.Name
+	}
+	return string(o.Kind) + " " + o.Schema + "." + o.Name
+}
+
+func (o *SchemaObject) String() string {
+	if o.Kind == OBJ_SCHEMA {
+		return string(o.Kind) + " " + o.Name
+	}
+	if o.Kind == OBJ_GRANT {
+		return fmt.Sprintf("%s on %s.%s to %s", o.Kind, o.Schema, o.Parent, o.Name)
+	}
+	if len(o.Parent) > 0 {
+		return fmt.Sprintf("%s %s.%s.%s", o.Kind, o.Schema, o.Parent, o.Name)
+	}
+	return fmt.Sprintf("%s %s.%s", o.Kind, o.Schema, o.Name)
+}
+
+// Schema is a normalized snapshot of the user defined objects in a database.
+// The objects are sorted by kind, schema, parent, position and name.
+type Schema struct {
+	Objects []SchemaObject
+
+	// Maps qualified view names to the qualified names of the views they
+	// depend on.
+	viewDeps map[string][]string
+}
+
+// Without returns a copy of the schema without the objects that also exist
+// in base, regardless of their definitions.
+func (s *Schema) Without(base *Schema) *Schema {
+	keys := make(map[string]bool, len(base.Objects))
+	for _, o := range base.Objects {
+		keys[o.Key()] = true
+	}
+
+	res := &Schema{viewDeps: s.viewDeps}
+	for _, o := range s.Objects {
+		if !keys[o.Key()] {
+			res.Objects = append(res.Objects, o)
+		}
+	}
+	return res
+}
+
+func (s *Schema) Lookup(kind SchemaObjectKind) []SchemaObject {
+	var res []SchemaObject
+	for _, o := range s.Objects {
+		if o.Kind == kind {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+var schemaObjectKindIx = func() map[SchemaObjectKind]int {
+	res := make(map[SchemaObjectKind]int, len(SchemaObjectKinds))
+	for i, k := range SchemaObjectKinds {
+		res[k] = i
+	}
+	return res
+}()
+
+func compareSchemaObjects(a *SchemaObject, b *SchemaObject) int {
+	if c := schemaObjectKindIx[a.Kind] - schemaObjectKindIx[b.Kind]; c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Schema, b.Schema); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Parent, b.Parent); c != 0 {
+		return c
+	}
+	if c := a.Position - b.Position; c != 0 {
+		return c
+	}
+	return strings.Compare(a.Name, b.Name)
+}
+
+func (s *Schema) sort() {
+	slices.SortStableFunc(s.Objects, func(a, b SchemaObject) int {
+		return compareSchemaObjects(&a, &b)
+	})
+}
+
+type SchemaOptions struct {
+	// Only inspect these schemas. Inspects all non-system schemas if empty.
+	Schemas []string
+	// Never inspect these schemas.
+	ExcludeSchemas []string
+	// Only inspect objects of these kinds. Inspects all kinds if empty.
+	Kinds []SchemaObjectKind
+	// Ignore these relations (and their columns, constraints, indexes,
+	// triggers and grants). Names may be schema qualified.
+	ExcludeRelations []string
+}
+
+func (o *SchemaOptions) includesKind(kind SchemaObjectKind) bool {
+	return o == nil || len(o.Kinds) == 0 || slices.Contains(o.Kinds, kind)
+}
+
+func (o *SchemaOptions) excludesRelation(schema string, name string) bool {
+	if o == nil {
+		return false
+	}
+	for _, rel := range o.ExcludeRelations {
+		if rel == name || rel == schema+"."+name {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *SchemaOptions) excludes(obj *SchemaObject) bool {
+	switch obj.Kind {
+	case OBJ_TABLE, OBJ_SEQUENCE, OBJ_VIEW, OBJ_MATERIALIZED_VIEW:
+		return o.excludesRelation(obj.Schema, obj.Name)
+	case OBJ_COLUMN, OBJ_CONSTRAINT, OBJ_TRIGGER:
+		return o.excludesRelation(obj.Schema, obj.Parent)
+	case OBJ_GRANT:
+		kind, name, _ := strings.Cut(obj.Parent, " ")
+		return kind != "SCHEMA" && kind != "FUNCTION" && kind != "PROCEDURE" &&
+			o.excludesRelation(obj.Schema, name)
+	default:
+		return false
+	}
+}
+
+const nspFilter = `n.nspname NOT IN ('pg_catalog', 'information_schema')
+  AND n.nspname NOT LIKE 'pg\_%'
+  AND ($1::text[] IS NULL OR n.nspname = ANY($1))
+  AND NOT (n.nspname = ANY($2))`
+
+func notFromExtension(oid string) string {
+	return `NOT EXISTS (
+    SELECT FROM pg_catalog.pg_depend d
+    WHERE d.objid = ` + oid + ` AND d.deptype = 'e'
+  )`
+}
+
+var schemaQueries = map[SchemaObjectKind]string{
+	OBJ_SCHEMA: `
+SELECT n.nspname, '', n.nspname, '', 0
+FROM pg_catalog.pg_namespace n
+WHERE ` + nspFilter + ` AND ` + notFromExtension("n.oid"),
+
+	OBJ_EXTENSION: `
+SELECT n.nspname, '', e.extname, e.extversion, 0
+FROM pg_catalog.pg_extension e
+JOIN pg_catalog.pg_namespace n ON n.oid = e.extnamespace
+WHERE ` + nspFilter,
+
+	OBJ_TYPE: `
+SELECT n.nspname, '', t.typname,
+  CASE t.typtype
+    WHEN 'e' THEN 'AS ENUM (' || COALESCE((
+      SELECT string_agg(quote_literal(e.enumlabel), ', ' ORDER BY e.enumsortorder)
+      FROM pg_catalog.pg_enum e
+      WHERE e.enumtypid = t.oid
+    ), '') || ')'
+    ELSE 'AS (' || COALESCE((
+      SELECT string_agg(quote_ident(a.attname) || ' ' || format_type(a.atttypid, a.atttypmod), ', ' ORDER BY a.attnum)
+      FROM pg_catalog.pg_attribute a
+      WHERE a.attrelid = t.typrelid AND a.attnum > 0 AND NOT a.attisdropped
+    ), '') || ')'
+  END,
+  0
+FROM pg_catalog.pg_type t
+JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+LEFT JOIN pg_catalog.pg_class c ON c.oid = t.typrelid
+WHERE (t.typtype = 'e' OR (t.typtype = 'c' AND c.relkind = 'c'))
+  AND ` + nspFilter + ` AND ` + notFromExtension("t.oid"),
+
+	OBJ_DOMAIN: `
+SELECT n.nspname, '', t.typname,
+  'AS ' || format_type(t.typbasetype, t.typtypmod)
+  || COALESCE(' DEFAULT ' || t.typdefault, '')
+  || CASE WHEN t.typnotnull THEN ' NOT NULL' ELSE '' END
+  || COALESCE((
+    SELECT string_agg(' CONSTRAINT ' || quote_ident(co.conname) || ' ' || pg_get_constraintdef(co.oid, true), '' ORDER BY co.conname)
+    FROM pg_catalog.pg_constraint co
+    WHERE co.contypid = t.oid AND co.contype <> 'n'
+  ), ''),
+  0
+FROM pg_catalog.pg_type t
+JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+WHERE t.typtype = 'd' AND ` + nspFilter + ` AND ` + notFromExtension("t.oid"),
+
+	OBJ_SEQUENCE: `
+SELECT n.nspname, '', c.relname,
+  'AS ' || format_type(s.seqtypid, NULL)
+  || ' INCREMENT BY ' || s.seqincrement
+  || ' MINVALUE ' || s.seqmin
+  || ' MAXVALUE ' || s.seqmax
+  || ' START WITH ' || s.seqstart
+  || ' CACHE ' || s.seqcache
+  || CASE WHEN s.seqcycle THEN ' CYCLE' ELSE ' NO CYCLE' END,
+  0
+FROM pg_catalog.pg_sequence s
+JOIN pg_catalog.pg_class c ON c.oid = s.seqrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE NOT EXISTS (
+    SELECT FROM pg_catalog.pg_depend d
+    WHERE d.objid = c.oid AND d.deptype = 'i'
+  )
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_FUNCTION: `
+SELECT n.nspname, '', p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+  pg_get_functiondef(p.oid), 0
+FROM pg_catalog.pg_proc p
+JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+WHERE p.prokind IN ('f', 'p')
+  AND ` + nspFilter + ` AND ` + notFromExtension("p.oid"),
+
+	OBJ_TABLE: `
+SELECT n.nspname, '', c.relname,
+  CASE
+    WHEN c.relispartition THEN 'PARTITION OF ' || (
+      SELECT '"' || replace(pn.nspname, '"', '""') || '"."' || replace(pc.relname, '"', '""') || '"'
+      FROM pg_catalog.pg_inherits i
+      JOIN pg_catalog.pg_class pc ON pc.oid = i.inhparent
+      JOIN pg_catalog.pg_namespace pn ON pn.oid = pc.relnamespace
+      WHERE i.inhrelid = c.oid
+    ) || ' ' || pg_get_expr(c.relpartbound, c.oid)
+      || CASE WHEN c.relkind = 'p' THEN ' PARTITION BY ' || pg_get_partkeydef(c.oid) ELSE '' END
+    WHEN c.relkind = 'p' THEN 'PARTITIONED TABLE ' || pg_get_partkeydef(c.oid)
+    WHEN c.relkind = 'f' THEN 'FOREIGN TABLE'
+    WHEN c.relpersistence = 'u' THEN 'UNLOGGED TABLE'
+    ELSE 'TABLE'
+  END,
+  0
+FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'f')
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_COLUMN: `
+SELECT n.nspname, c.relname, a.attname,
+  format_type(a.atttypid, a.atttypmod)
+  || CASE a.attidentity
+       WHEN 'a' THEN ' GENERATED ALWAYS AS IDENTITY'
+       WHEN 'd' THEN ' GENERATED BY DEFAULT AS IDENTITY'
+       ELSE ''
+     END
+  || CASE
+       WHEN a.attgenerated = 's' THEN ' GENERATED ALWAYS AS (' || pg_get_expr(ad.adbin, ad.adrelid) || ') STORED'
+       WHEN ad.adbin IS NOT NULL THEN ' DEFAULT ' || pg_get_expr(ad.adbin, ad.adrelid)
+       ELSE ''
+     END
+  || CASE WHEN a.attnotnull THEN ' NOT NULL' ELSE '' END,
+  row_number() OVER (PARTITION BY a.attrelid ORDER BY a.attnum)::int
+FROM pg_catalog.pg_attribute a
+JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_catalog.pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+WHERE a.attnum > 0 AND NOT a.attisdropped
+  AND c.relkind IN ('r', 'p', 'f')
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_CONSTRAINT: `
+SELECT n.nspname, c.relname, co.conname, pg_get_constraintdef(co.oid, true), 0
+FROM pg_catalog.pg_constraint co
+JOIN pg_catalog.pg_class c ON c.oid = co.conrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE co.contype <> 'n'
+  AND co.conislocal AND co.conparentid = 0
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_INDEX: `
+SELECT n.nspname, '', ic.relname, pg_get_indexdef(i.indexrelid), 0
+FROM pg_catalog.pg_index i
+JOIN pg_catalog.pg_class ic ON ic.oid = i.indexrelid
+JOIN pg_catalog.pg_class c ON c.oid = i.indrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = ic.relnamespace
+WHERE NOT EXISTS (
+    SELECT FROM pg_catalog.pg_constraint co
+    WHERE co.conindid = i.indexrelid AND co.contype IN ('p', 'u', 'x')
+  )
+  AND NOT ic.relispartition
+  AND c.relkind IN ('r', 'p', 'm')
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_VIEW: `
+SELECT n.nspname, '', c.relname, pg_get_viewdef(c.oid, true), 0
+FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind = 'v'
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_MATERIALIZED_VIEW: `
+SELECT n.nspname, '', c.relname, pg_get_viewdef(c.oid, true), 0
+FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind = 'm'
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_TRIGGER: `
+SELECT n.nspname, c.relname, t.tgname, pg_get_triggerdef(t.oid, true), 0
+FROM pg_catalog.pg_trigger t
+JOIN pg_catalog.pg_class c ON c.oid = t.tgrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE NOT t.tgisinternal AND t.tgparentid = 0
+  AND ` + nspFilter + ` AND ` + notFromExtension("c.oid"),
+
+	OBJ_GRANT: `
+SELECT n.nspname, acl.target,
+  CASE WHEN acl.grantee = 0 THEN 'PUBLIC' ELSE quote_ident(pg_get_userbyid(acl.grantee)) END,
+  string_agg(acl.privilege_type, ', ' ORDER BY acl.privilege_type),
+  0
+FROM (
+  SELECT c.relnamespace AS nsp, c.relowner AS owner, c.oid AS objid,
+    CASE c.relkind WHEN 'S' THEN 'SEQUENCE ' ELSE 'TABLE ' END || quote_ident(c.relname) AS target,
+    (aclexplode(c.relacl)).*
+  FROM pg_catalog.pg_class c
+  WHERE c.relkind IN ('r', 'p', 'f', 'v', 'm', 'S') AND c.relacl IS NOT NULL
+  UNION ALL
+  SELECT n.oid, n.nspowner, n.oid,
+    'SCHEMA ' || quote_ident(n.nspname),
+    (aclexplode(n.nspacl)).*
+  FROM pg_catalog.pg_namespace n
+  WHERE n.nspacl IS NOT NULL
+  UNION ALL
+  SELECT p.pronamespace, p.proowner, p.oid,
+    CASE p.prokind WHEN 'p' THEN 'PROCEDURE ' ELSE 'FUNCTION ' END
+      || quote_ident(p.proname) || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+    (aclexplode(p.proacl)).*
+  FROM pg_catalog.pg_proc p
+  WHERE p.prokind IN ('f', 'p') AND p.proacl IS NOT NULL
+) acl
+JOIN pg_catalog.pg_namespace n ON n.oid = acl.nsp
+WHERE acl.grantee <> acl.owner
+  AND ` + nspFilter + ` AND ` + notFromExtension("acl.objid") + `
+GROUP BY n.nspname, acl.target, acl.grantee`,
+}
+
+// InspectSchema reads the user defined objects of the database from
+// `pg_catalog`. Objects that belong to extensions and system schemas are
+// never included.
+func InspectSchema(ctx context.Context, conn queryer, opts *SchemaOptions) (res *Schema, err error) {
+	var schemas []string
+	excludeSchemas := []string{}
+	if opts != nil {
+		if len(opts.Schemas) > 0 {
+			schemas = opts.Schemas
+		}
+		excludeSchemas = append(excludeSchemas, opts.ExcludeSchemas...)
+	}
+
+	res = &Schema{}
+	for _, kind := range SchemaObjectKinds {
+		if !opts.includesKind(kind) {
+			continue
+		}
+
+		rows, err := conn.Query(ctx, schemaQueries[kind], schemas, excludeSchemas)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to inspect %s objects: %w", kind, err)
+		}
+
+		for rows.Next() {
+			obj := SchemaObject{Kind: kind}
+			err = rows.Scan(&obj.Schema, &obj.Parent, &obj.Name, &obj.Definition, &obj.Position)
+			if err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("Failed to inspect %s objects: %w", kind, err)
+			}
+
+			if !opts.excludes(&obj) {
+				res.Objects = append(res.Objects, obj)
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("Failed to inspect %s objects: %w", kind, err)
+		}
+	}
+
+	if opts.includesKind(OBJ_VIEW) || opts.includesKind(OBJ_MATERIALIZED_VIEW) {
+		res.viewDeps, err = inspectViewDependencies(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res.sort()
+	return res, nil
+}
+
+func inspectViewDependencies(ctx context.Context, conn queryer) (map[string][]string, error) {
+	rows, err := conn.Query(ctx, `
+SELECT DISTINCT vn.nspname || '.' || v.relname, rn.nspname || '.' || r.relname
+FROM pg_catalog.pg_depend d
+JOIN pg_catalog.pg_rewrite rw ON rw.oid = d.objid
+JOIN pg_catalog.pg_class v ON v.oid = rw.ev_class
+JOIN pg_catalog.pg_namespace vn ON vn.oid = v.relnamespace
+JOIN pg_catalog.pg_class r ON r.oid = d.refobjid
+JOIN pg_catalog.pg_namespace rn ON rn.oid = r.relnamespace
+WHERE d.classid = 'pg_catalog.pg_rewrite'::regclass
+  AND d.refclassid = 'pg_catalog.pg_class'::regclass
+  AND r.relkind IN ('v', 'm')
+  AND r.oid <> v.oid
+`)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to inspect view dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	res := make(map[string][]string)
+	for rows.Next() {
+		var view, dep string
+		if err := rows.Scan(&view, &dep); err != nil {
+			return nil, fmt.Errorf("Failed to inspect view dependencies: %w", err)
+		}
+		res[view] = append(res[view], dep)
+	}
+
+	return res, rows.Err()
+}
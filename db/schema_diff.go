@@ -0,0 +1,123 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type SchemaChange rune
+
+const (
+	SCHEMA_ADDED   SchemaChange = '+'
+	SCHEMA_REMOVED SchemaChange = '-'
+	SCHEMA_CHANGED SchemaChange = '~'
+)
+
+// SchemaDiffEntry describes a single object that differs between two
+// schemas. From is nil if the object was added, To is nil if the object
+// was removed.
+type SchemaDiffEntry struct {
+	From *SchemaObject
+	To   *SchemaObject
+}
+
+func (e *SchemaDiffEntry) Change() SchemaChange {
+	if e.From == nil {
+		return SCHEMA_ADDED
+	} else if e.To == nil {
+		return SCHEMA_REMOVED
+	} else {
+		return SCHEMA_CHANGED
+	}
+}
+
+func (e *SchemaDiffEntry) Object() *SchemaObject {
+	if e.To != nil {
+		return e.To
+	}
+	return e.From
+}
+
+type SchemaDiff struct {
+	FromLabel string
+	ToLabel   string
+	Entries   []SchemaDiffEntry
+}
+
+// DiffSchemas compares two schemas object by object. The entries are
+// ordered in the same way as the objects of the schemas.
+func DiffSchemas(from *Schema, to *Schema) *SchemaDiff {
+	res := &SchemaDiff{FromLabel: "from", ToLabel: "to"}
+
+	fromObjects := make(map[string]*SchemaObject, len(from.Objects))
+	for i := range from.Objects {
+		fromObjects[from.Objects[i].Key()] = &from.Objects[i]
+	}
+
+	toKeys := make(map[string]struct{}, len(to.Objects))
+	for i := range to.Objects {
+		obj := &to.Objects[i]
+		key := obj.Key()
+		toKeys[key] = struct{}{}
+
+		fromObj, present := fromObjects[key]
+		if !present {
+			res.Entries = append(res.Entries, SchemaDiffEntry{To: obj})
+		} else if fromObj.Definition != obj.Definition || fromObj.Position != obj.Position {
+			res.Entries = append(res.Entries, SchemaDiffEntry{From: fromObj, To: obj})
+		}
+	}
+
+	for i := range from.Objects {
+		obj := &from.Objects[i]
+		if _, present := toKeys[obj.Key()]; !present {
+			res.Entries = append(res.Entries, SchemaDiffEntry{From: obj})
+		}
+	}
+
+	slices.SortStableFunc(res.Entries, func(a, b SchemaDiffEntry) int {
+		return compareSchemaObjects(a.Object(), b.Object())
+	})
+
+	return res
+}
+
+func (d *SchemaDiff) Empty() bool {
+	return d == nil || len(d.Entries) == 0
+}
+
+func definitionLines(prefix string, obj *SchemaObject) string {
+	def := obj.Definition
+	if obj.Kind == OBJ_COLUMN {
+		def = fmt.Sprintf("#%d %s", obj.Position, def)
+	}
+
+	lines := strings.Split(strings.TrimRight(def, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d *SchemaDiff) String() string {
+	if d.Empty() {
+		return ""
+	}
+
+	width := max(len(d.FromLabel), len(d.ToLabel)) + 2
+
+	var b strings.Builder
+	for _, e := range d.Entries {
+		fmt.Fprintf(&b, "%c %s\n", e.Change(), e.Object())
+		if e.From != nil {
+			b.WriteString(definitionLines(fmt.Sprintf("    %-*s", width, d.FromLabel+":"), e.From))
+			b.WriteByte('\n')
+		}
+		if e.To != nil {
+			b.WriteString(definitionLines(fmt.Sprintf("    %-*s", width, d.ToLabel+":"), e.To))
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
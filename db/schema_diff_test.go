@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	from := &Schema{Objects: []SchemaObject{
+		{Kind: OBJ_TABLE, Schema: "public", Name: "users", Definition: "TABLE"},
+		{Kind: OBJ_COLUMN, Schema: "public", Parent: "users", Name: "id", Definition: "integer NOT NULL", Position: 1},
+		{Kind: OBJ_COLUMN, Schema: "public", Parent: "users", Name: "email", Definition: "text", Position: 2},
+	}}
+	to := &Schema{Objects: []SchemaObject{
+		{Kind: OBJ_TABLE, Schema: "public", Name: "users", Definition: "TABLE"},
+		{Kind: OBJ_COLUMN, Schema: "public", Parent: "users", Name: "id", Definition: "integer NOT NULL", Position: 1},
+		{Kind: OBJ_COLUMN, Schema: "public", Parent: "users", Name: "email", Definition: "character varying(255)", Position: 2},
+		{Kind: OBJ_INDEX, Schema: "public", Name: "users_email_idx", Definition: "CREATE INDEX ..."},
+	}}
+
+	diff := DiffSchemas(from, to)
+	if len(diff.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d:\n%s", len(diff.Entries), diff)
+	}
+
+	if c := diff.Entries[0].Change(); c != SCHEMA_CHANGED {
+		t.Errorf("expected first entry to be changed, got %c", c)
+	}
+	if name := diff.Entries[0].Object().Name; name != "email" {
+		t.Errorf("expected changed column email, got %s", name)
+	}
+	if c := diff.Entries[1].Change(); c != SCHEMA_ADDED {
+		t.Errorf("expected second entry to be added, got %c", c)
+	}
+
+	if !DiffSchemas(to, to).Empty() {
+		t.Errorf("expected no differences between identical schemas")
+	}
+}
+
+func TestSchemaWithout(t *testing.T) {
+	base := &Schema{Objects: []SchemaObject{
+		{Kind: OBJ_SCHEMA, Name: "auth"},
+		{Kind: OBJ_TABLE, Schema: "auth", Name: "users", Definition: "TABLE"},
+	}}
+	schema := &Schema{Objects: []SchemaObject{
+		{Kind: OBJ_SCHEMA, Name: "auth"},
+		{Kind: OBJ_TABLE, Schema: "auth", Name: "users", Definition: "UNLOGGED TABLE"},
+		{Kind: OBJ_TABLE, Schema: "public", Name: "users", Definition: "TABLE"},
+	}}
+
+	res := schema.Without(base)
+	if len(res.Objects) != 1 {
+		t.Fatalf("expected 1 object, got %d: %v", len(res.Objects), res.Objects)
+	}
+	if o := res.Objects[0]; o.Schema != "public" || o.Name != "users" {
+		t.Errorf("expected table public.users, got %s", o.Key())
+	}
+}
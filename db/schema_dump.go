@@ -0,0 +1,353 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DumpSchema inspects the database and renders its schema as normalized
+// DDL. The output only depends on the catalog contents, so two databases
+// with the same schema always produce the exact same text.
+func DumpSchema(ctx context.Context, conn queryer, opts *SchemaOptions) (string, error) {
+	schema, err := InspectSchema(ctx, conn, opts)
+	if err != nil {
+		return "", err
+	}
+	return schema.DDL(), nil
+}
+
+func qualified(schema string, name string) string {
+	return pgx.Identifier{schema, name}.Sanitize()
+}
+
+func terminated(stmt string) string {
+	stmt = strings.TrimRight(stmt, " \t\n;")
+	return stmt + ";\n"
+}
+
+// DDL renders the schema as a sequence of SQL statements, ordered such
+// that the statements can be replayed on an empty database.
+func (s *Schema) DDL() string {
+	var b strings.Builder
+
+	b.WriteString("SET check_function_bodies = false;\n")
+
+	section := func(title string) {
+		b.WriteString("\n--\n-- " + title + "\n--\n\n")
+	}
+
+	if objs := s.Lookup(OBJ_SCHEMA); len(objs) > 0 {
+		section("Schemas")
+		for _, o := range objs {
+			fmt.Fprintf(&b, "CREATE SCHEMA IF NOT EXISTS %s;\n", pgx.Identifier{o.Name}.Sanitize())
+		}
+	}
+
+	if objs := s.Lookup(OBJ_EXTENSION); len(objs) > 0 {
+		section("Extensions")
+		for _, o := range objs {
+			fmt.Fprintf(&b, "CREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s;\n",
+				pgx.Identifier{o.Name}.Sanitize(),
+				pgx.Identifier{o.Schema}.Sanitize(),
+			)
+		}
+	}
+
+	if objs := s.Lookup(OBJ_TYPE); len(objs) > 0 {
+		section("Types")
+		for _, o := range objs {
+			fmt.Fprintf(&b, "CREATE TYPE %s %s;\n", qualified(o.Schema, o.Name), o.Definition)
+		}
+	}
+
+	if objs := s.Lookup(OBJ_DOMAIN); len(objs) > 0 {
+		section("Domains")
+		for _, o := range objs {
+			fmt.Fprintf(&b, "CREATE DOMAIN %s %s;\n", qualified(o.Schema, o.Name), o.Definition)
+		}
+	}
+
+	if objs := s.Lookup(OBJ_SEQUENCE); len(objs) > 0 {
+		section("Sequences")
+		for _, o := range objs {
+			fmt.Fprintf(&b, "CREATE SEQUENCE %s %s;\n", qualified(o.Schema, o.Name), o.Definition)
+		}
+	}
+
+	// Tables come before the functions, as functions may use the row types
+	// of tables. The column defaults are set after the functions, as they
+	// may call them.
+	tables := s.sortedTables()
+	columns := s.Lookup(OBJ_COLUMN)
+	if len(tables) > 0 {
+		section("Tables")
+		for i, o := range tables {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			writeTable(&b, &o, columns)
+		}
+	}
+
+	if objs := s.Lookup(OBJ_FUNCTION); len(objs) > 0 {
+		section("Functions")
+		for i, o := range objs {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(terminated(o.Definition))
+		}
+	}
+
+	if defaults := columnDefaults(tables, columns); len(defaults) > 0 {
+		section("Defaults")
+		for _, d := range defaults {
+			b.WriteString(d)
+		}
+	}
+
+	if objs := s.Lookup(OBJ_CONSTRAINT); len(objs) > 0 {
+		section("Constraints")
+		// Foreign keys last, as they may reference the other constraints.
+		slices.SortStableFunc(objs, func(a, b SchemaObject) int {
+			aFk := strings.HasPrefix(a.Definition, "FOREIGN KEY")
+			bFk := strings.HasPrefix(b.Definition, "FOREIGN KEY")
+			if aFk == bFk {
+				return 0
+			} else if aFk {
+				return 1
+			} else {
+				return -1
+			}
+		})
+		for _, o := range objs {
+			fmt.Fprintf(&b, "ALTER TABLE %s ADD CONSTRAINT %s %s;\n",
+				qualified(o.Schema, o.Parent),
+				pgx.Identifier{o.Name}.Sanitize(),
+				o.Definition,
+			)
+		}
+	}
+
+	if objs := s.sortedViews(); len(objs) > 0 {
+		section("Views")
+		for i, o := range objs {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			kind := "VIEW"
+			if o.Kind == OBJ_MATERIALIZED_VIEW {
+				kind = "MATERIALIZED VIEW"
+			}
+			fmt.Fprintf(&b, "CREATE %s %s AS\n%s", kind, qualified(o.Schema, o.Name), terminated(o.Definition))
+		}
+	}
+
+	if objs := s.Lookup(OBJ_INDEX); len(objs) > 0 {
+		section("Indexes")
+		for _, o := range objs {
+			b.WriteString(terminated(o.Definition))
+		}
+	}
+
+	if objs := s.Lookup(OBJ_TRIGGER); len(objs) > 0 {
+		section("Triggers")
+		for _, o := range objs {
+			b.WriteString(terminated(o.Definition))
+		}
+	}
+
+	if objs := s.Lookup(OBJ_GRANT); len(objs) > 0 {
+		section("Grants")
+		for _, o := range objs {
+			target := o.Parent
+			if kind, name, found := strings.Cut(o.Parent, " "); found && kind != "SCHEMA" {
+				target = kind + " " + pgx.Identifier{o.Schema}.Sanitize() + "." + name
+			}
+			fmt.Fprintf(&b, "GRANT %s ON %s TO %s;\n", o.Definition, target, o.Name)
+		}
+	}
+
+	return b.String()
+}
+
+const partitionOf = "PARTITION OF "
+
+// splitColumnDefault splits the DEFAULT expression off the definition of a
+// column. Returns an empty expression if the column has no default.
+func splitColumnDefault(definition string) (column string, expr string) {
+	// Skip the DEFAULT of `GENERATED BY DEFAULT AS IDENTITY`.
+	offset := 0
+	for {
+		i := strings.Index(definition[offset:], " DEFAULT ")
+		if i < 0 {
+			return definition, ""
+		}
+		i += offset
+		if strings.HasSuffix(definition[:i], " BY") {
+			offset = i + len(" DEFAULT ")
+			continue
+		}
+
+		column, rest := definition[:i], definition[i+len(" DEFAULT "):]
+		expr, notNull := strings.CutSuffix(rest, " NOT NULL")
+		if notNull {
+			column += " NOT NULL"
+		}
+		return column, expr
+	}
+}
+
+// tableColumns returns the columns of the table.
+func tableColumns(table *SchemaObject, columns []SchemaObject) []SchemaObject {
+	var res []SchemaObject
+	for _, c := range columns {
+		if c.Schema == table.Schema && c.Parent == table.Name {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func writeTable(b *strings.Builder, table *SchemaObject, columns []SchemaObject) {
+	name := qualified(table.Schema, table.Name)
+
+	// Partitions inherit the columns of their parent.
+	if strings.HasPrefix(table.Definition, partitionOf) {
+		fmt.Fprintf(b, "CREATE TABLE %s %s;\n", name, table.Definition)
+		return
+	}
+
+	kind, partitionBy, _ := strings.Cut(table.Definition, " TABLE")
+	switch kind {
+	case "FOREIGN":
+		fmt.Fprintf(b, "-- Foreign table %s omitted.\n", name)
+		return
+	case "UNLOGGED":
+		b.WriteString("CREATE UNLOGGED TABLE ")
+	default:
+		b.WriteString("CREATE TABLE ")
+	}
+
+	b.WriteString(name)
+	b.WriteString(" (")
+
+	cols := tableColumns(table, columns)
+	for i, c := range cols {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		definition, _ := splitColumnDefault(c.Definition)
+		fmt.Fprintf(b, "\n    %s %s", pgx.Identifier{c.Name}.Sanitize(), definition)
+	}
+
+	if len(cols) > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteByte(')')
+
+	if partitionBy = strings.TrimSpace(partitionBy); len(partitionBy) > 0 {
+		b.WriteString(" PARTITION BY " + partitionBy)
+	}
+	b.WriteString(";\n")
+}
+
+// columnDefaults returns the statements that set the column defaults of the
+// tables. Partitions and foreign tables are skipped, as their columns are not
+// created by writeTable.
+func columnDefaults(tables []SchemaObject, columns []SchemaObject) []string {
+	var res []string
+	for _, t := range tables {
+		if strings.HasPrefix(t.Definition, partitionOf) || strings.HasPrefix(t.Definition, "FOREIGN") {
+			continue
+		}
+		for _, c := range tableColumns(&t, columns) {
+			if _, expr := splitColumnDefault(c.Definition); len(expr) > 0 {
+				res = append(res, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;\n",
+					qualified(t.Schema, t.Name),
+					pgx.Identifier{c.Name}.Sanitize(),
+					expr,
+				))
+			}
+		}
+	}
+	return res
+}
+
+// sortedTables returns the tables ordered by name, except that partitions
+// are always placed after their parent table.
+func (s *Schema) sortedTables() []SchemaObject {
+	tables := s.Lookup(OBJ_TABLE)
+
+	done := make(map[int]bool, len(tables))
+	res := make([]SchemaObject, 0, len(tables))
+
+	var visit func(i int, path map[int]bool)
+	visit = func(i int, path map[int]bool) {
+		if done[i] || path[i] {
+			return
+		}
+		path[i] = true
+
+		if parent, ok := strings.CutPrefix(tables[i].Definition, partitionOf); ok {
+			for j := range tables {
+				if strings.HasPrefix(parent, qualified(tables[j].Schema, tables[j].Name)+" ") {
+					visit(j, path)
+				}
+			}
+		}
+
+		done[i] = true
+		res = append(res, tables[i])
+	}
+
+	for i := range tables {
+		visit(i, make(map[int]bool))
+	}
+
+	return res
+}
+
+// sortedViews returns the views and materialized views ordered by name,
+// except that views are always placed after the views they depend on.
+func (s *Schema) sortedViews() []SchemaObject {
+	views := append(s.Lookup(OBJ_VIEW), s.Lookup(OBJ_MATERIALIZED_VIEW)...)
+	slices.SortFunc(views, func(a, b SchemaObject) int {
+		return strings.Compare(a.Schema+"."+a.Name, b.Schema+"."+b.Name)
+	})
+
+	done := make(map[string]bool, len(views))
+	res := make([]SchemaObject, 0, len(views))
+
+	var visit func(o *SchemaObject, path map[string]bool)
+	visit = func(o *SchemaObject, path map[string]bool) {
+		name := o.Schema + "." + o.Name
+		if done[name] || path[name] {
+			return
+		}
+		path[name] = true
+
+		deps := slices.Clone(s.viewDeps[name])
+		slices.Sort(deps)
+		for _, dep := range deps {
+			for i := range views {
+				if views[i].Schema+"."+views[i].Name == dep {
+					visit(&views[i], path)
+				}
+			}
+		}
+
+		done[name] = true
+		res = append(res, *o)
+	}
+
+	for i := range views {
+		visit(&views[i], make(map[string]bool))
+	}
+
+	return res
+}
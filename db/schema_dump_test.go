@@ -0,0 +1,83 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaDDL(t *testing.T) {
+	cases := []struct {
+		name     string
+		objects  []SchemaObject
+		expected []string
+	}{
+		{
+			name: "column defaults after functions",
+			objects: []SchemaObject{
+				{Kind: OBJ_FUNCTION, Schema: "public", Name: "new_id()", Definition: "CREATE FUNCTION public.new_id() RETURNS integer LANGUAGE sql AS $$ SELECT 1 $$"},
+				{Kind: OBJ_TABLE, Schema: "public", Name: "users", Definition: "TABLE"},
+				{Kind: OBJ_COLUMN, Schema: "public", Parent: "users", Name: "id", Definition: "integer DEFAULT new_id() NOT NULL", Position: 1},
+				{Kind: OBJ_COLUMN, Schema: "public", Parent: "users", Name: "n", Definition: "integer GENERATED BY DEFAULT AS IDENTITY NOT NULL", Position: 2},
+			},
+			expected: []string{
+				"CREATE TABLE \"public\".\"users\" (\n    \"id\" integer NOT NULL,\n    \"n\" integer GENERATED BY DEFAULT AS IDENTITY NOT NULL\n);\n",
+				"CREATE FUNCTION public.new_id()",
+				"ALTER TABLE \"public\".\"users\" ALTER COLUMN \"id\" SET DEFAULT new_id();\n",
+			},
+		},
+		{
+			name: "functions after tables",
+			objects: []SchemaObject{
+				{Kind: OBJ_FUNCTION, Schema: "public", Name: "active_users()", Definition: "CREATE FUNCTION public.active_users() RETURNS SETOF public.users LANGUAGE sql BEGIN ATOMIC SELECT * FROM public.users; END"},
+				{Kind: OBJ_TABLE, Schema: "public", Name: "users", Definition: "TABLE"},
+			},
+			expected: []string{
+				"CREATE TABLE \"public\".\"users\" ();\n",
+				"CREATE FUNCTION public.active_users()",
+			},
+		},
+		{
+			name: "partitions after their parent",
+			objects: []SchemaObject{
+				{Kind: OBJ_TABLE, Schema: "public", Name: "a_events_2024", Definition: `PARTITION OF "public"."events" FOR VALUES FROM ('2024-01-01') TO ('2025-01-01')`},
+				{Kind: OBJ_TABLE, Schema: "public", Name: "events", Definition: "PARTITIONED TABLE RANGE (at)"},
+				{Kind: OBJ_COLUMN, Schema: "public", Parent: "a_events_2024", Name: "at", Definition: "date NOT NULL", Position: 1},
+				{Kind: OBJ_COLUMN, Schema: "public", Parent: "events", Name: "at", Definition: "date NOT NULL", Position: 1},
+			},
+			expected: []string{
+				"CREATE TABLE \"public\".\"events\" (\n    \"at\" date NOT NULL\n) PARTITION BY RANGE (at);\n",
+				`CREATE TABLE "public"."a_events_2024" PARTITION OF "public"."events" FOR VALUES FROM ('2024-01-01') TO ('2025-01-01');` + "\n",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ddl := (&Schema{Objects: c.objects}).DDL()
+
+			offset := 0
+			for _, stmt := range c.expected {
+				i := strings.Index(ddl[offset:], stmt)
+				if i < 0 {
+					t.Fatalf("Expected %q after offset %d in:\n%s", stmt, offset, ddl)
+				}
+				offset += i + len(stmt)
+			}
+		})
+	}
+}
+
+func TestSplitColumnDefault(t *testing.T) {
+	cases := map[string][2]string{
+		"text":                             {"text", ""},
+		"integer DEFAULT 1 NOT NULL":       {"integer NOT NULL", "1"},
+		"text DEFAULT 'a DEFAULT b'::text": {"text", "'a DEFAULT b'::text"},
+		"bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL": {"bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL", ""},
+	}
+	for definition, expected := range cases {
+		column, expr := splitColumnDefault(definition)
+		if column != expected[0] || expr != expected[1] {
+			t.Errorf("splitColumnDefault(%q) = %q, %q, expected %q, %q", definition, column, expr, expected[0], expected[1])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SessionTimeouts are the timeouts of a database session. Zero timeouts
+// keep the default of the server.
+type SessionTimeouts struct {
+	LockTimeout                     time.Duration
+	StatementTimeout                time.Duration
+	IdleInTransactionSessionTimeout time.Duration
+}
+
+func (t *SessionTimeouts) IsZero() bool {
+	return t == nil || *t == SessionTimeouts{}
+}
+
+func (t *SessionTimeouts) settings() map[string]time.Duration {
+	return map[string]time.Duration{
+		"lock_timeout":                        t.LockTimeout,
+		"statement_timeout":                   t.StatementTimeout,
+		"idle_in_transaction_session_timeout": t.IdleInTransactionSessionTimeout,
+	}
+}
+
+// RuntimeParams returns the non-zero timeouts as connection runtime
+// parameters in milliseconds.
+func (t *SessionTimeouts) RuntimeParams() map[string]string {
+	res := make(map[string]string)
+	if t == nil {
+		return res
+	}
+	for name, d := range t.settings() {
+		if d > 0 {
+			res[name] = strconv.FormatInt(d.Milliseconds(), 10)
+		}
+	}
+	return res
+}
+
+// Apply sets the non-zero timeouts on the session of the connection. Call
+// the returned function to reset them to the defaults again.
+func (t *SessionTimeouts) Apply(ctx context.Context, conn *pgx.Conn) (reset func(context.Context) error, err error) {
+	if t.IsZero() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	params := t.RuntimeParams()
+	for name, val := range params {
+		_, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", name, val)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set %s: %w", name, err)
+		}
+	}
+
+	return func(ctx context.Context) error {
+		for name := range params {
+			_, err := conn.Exec(ctx, "RESET "+name)
+			if err != nil {
+				return fmt.Errorf("Failed to reset %s: %w", name, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// IsLockTimeout returns true if the error was caused by a lock timeout.
+func IsLockTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "55P03"
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Vars substitutes `${NAME}` and `${NAME:-default}` placeholders in SQL.
+//
+// Placeholders are resolved from Values first and then from the environment
+// if LookupEnv is set. Unresolved placeholders are left as-is, unless Strict
+// is set, in which case they are an error.
+type Vars struct {
+	Values    map[string]string
+	LookupEnv bool
+	Strict    bool
+}
+
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+func (v *Vars) Lookup(name string) (string, bool) {
+	if val, ok := v.Values[name]; ok {
+		return val, true
+	}
+	if v.LookupEnv {
+		return os.LookupEnv(name)
+	}
+	return "", false
+}
+
+// Expand substitutes the placeholders in the sql.
+func (v *Vars) Expand(sql string) (string, error) {
+	if v == nil || !strings.Contains(sql, "${") {
+		return sql, nil
+	}
+
+	var undefined []string
+	res := varPattern.ReplaceAllStringFunc(sql, func(match string) string {
+		groups := varPattern.FindStringSubmatch(match)
+		if val, ok := v.Lookup(groups[1]); ok {
+			return val
+		}
+		if strings.Contains(match, ":-") {
+			return groups[2]
+		}
+		undefined = append(undefined, groups[1])
+		return match
+	})
+
+	if v.Strict && len(undefined) > 0 {
+		return sql, fmt.Errorf("Undefined variables: %s", strings.Join(undefined, ", "))
+	}
+
+	return res, nil
+}
+
+type expandFS struct {
+	fsys fs.FS
+	vars *Vars
+}
+
+// ExpandFS wraps the filesystem such that the placeholders in the `.sql`
+// files are substituted when they are read.
+func ExpandFS(fsys fs.FS, vars *Vars) fs.FS {
+	if fsys == nil || vars == nil {
+		return fsys
+	}
+	return &expandFS{fsys, vars}
+}
+
+func (f *expandFS) Open(name string) (fs.File, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil || path.Ext(name) != ".sql" {
+		return file, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded, err := f.vars.Expand(string(content))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &expandedFile{
+		Reader: bytes.NewReader([]byte(expanded)),
+		info:   expandedFileInfo{info, int64(len(expanded))},
+	}, nil
+}
+
+type expandedFile struct {
+	*bytes.Reader
+	info expandedFileInfo
+}
+
+func (f *expandedFile) Stat() (fs.FileInfo, error) {
+	return &f.info, nil
+}
+
+func (f *expandedFile) Close() error {
+	return nil
+}
+
+type expandedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i *expandedFileInfo) Size() int64 {
+	return i.size
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVarsExpand(t *testing.T) {
+	vars := &Vars{Values: map[string]string{"ROLE": "app_rw"}}
+
+	res, err := vars.Expand("GRANT SELECT ON t TO ${ROLE}; SET x = '${SPACE:-pg_default}'; SELECT $1, $$${MISSING}$$;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "GRANT SELECT ON t TO app_rw; SET x = 'pg_default'; SELECT $1, $$${MISSING}$$;"
+	if res != expected {
+		t.Errorf("expected %q, got %q", expected, res)
+	}
+
+	vars.Strict = true
+	if _, err := vars.Expand("SELECT '${MISSING}'"); err == nil {
+		t.Errorf("expected strict expand to fail on undefined variable")
+	}
+}
+
+func TestExpandFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_roles.sql": {Data: []byte("GRANT SELECT ON t TO ${ROLE};")},
+		"README.md":     {Data: []byte("Grants to ${ROLE}.")},
+	}
+	expanded := ExpandFS(fsys, &Vars{Values: map[string]string{"ROLE": "app_readwrite"}})
+
+	content, err := fs.ReadFile(expanded, "001_roles.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "GRANT SELECT ON t TO app_readwrite;"; string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+
+	info, err := fs.Stat(expanded, "001_roles.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(content)) {
+		t.Errorf("expected the size of the expanded content %d, got %d", len(content), info.Size())
+	}
+
+	content, err = fs.ReadFile(expanded, "README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "Grants to ${ROLE}." {
+		t.Errorf("expected files other than .sql files to pass through, got %q", content)
+	}
+}
+
+func TestExpandFSStrict(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_roles.sql": {Data: []byte("GRANT SELECT ON t TO ${MISSING};")},
+	}
+	expanded := ExpandFS(fsys, &Vars{Strict: true})
+
+	_, err := fs.ReadFile(expanded, "001_roles.sql")
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "001_roles.sql" {
+		t.Errorf("expected a PathError of the file, got %v", err)
+	}
+}
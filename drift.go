@@ -0,0 +1,127 @@
+package psqlmanager
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+)
+
+// DriftAction compares the schema of a database with the schema that
+// the migrations produce at the current version of that database.
+type DriftAction struct {
+	*db.Database
+	Options db.SchemaOptions
+}
+
+func migrationVersionInDatabase(ctx context.Context, database *db.Database, config *Config, factory *psqlmigrate.ProviderFactory) (int64, error) {
+	connConfig, err := config.RootConnConfig()
+	if err != nil {
+		return 0, err
+	}
+	connConfig.Database = database.Name
+
+	provider, err := factory.OpenProvider(ctx, connConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer provider.Close()
+
+	return provider.GetDBVersion(ctx)
+}
+
+func (a *DriftAction) RunWithRootConn(ctx context.Context, rootConn *pgx.Conn, config *Config) (*db.SchemaDiff, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+
+	database := a.Database
+	if database == nil {
+		database = config.TargetDatabase()
+	}
+
+	sets, err := config.migrationSetFactories()
+	if err != nil {
+		return nil, fmt.Errorf("Failed DriftAction \"%s\": %w", database.Name, err)
+	}
+
+	versions := make([]int64, len(sets))
+	for i, set := range sets {
+		versions[i], err = migrationVersionInDatabase(ctx, database, config, set.factory)
+		if err != nil {
+			return nil, fmt.Errorf("Failed DriftAction \"%s\": Version: %w", database.Name, err)
+		}
+	}
+
+	diff, err := diffWithMigrations(ctx, rootConn, database, config, sets, versions, a.Options)
+	if err != nil {
+		return nil, fmt.Errorf("Failed DriftAction \"%s\": %w", database.Name, err)
+	}
+
+	return diff, nil
+}
+
+// diffWithMigrations compares the schema of a reference database, built by
+// migrating each set up to its version, with the schema of the database.
+func diffWithMigrations(ctx context.Context, rootConn *pgx.Conn, database *db.Database, config *Config, sets []migrationSetFactory, versions []int64, options db.SchemaOptions) (*db.SchemaDiff, error) {
+	labels := make([]string, len(sets))
+	for i, set := range sets {
+		labels[i] = fmt.Sprintf("%s@%d", cmp.Or(set.name, "migrations"), versions[i])
+	}
+
+	// Build the reference database from the migrations.
+	referenceAction := InitDatabaseAction{
+		Database:   &db.Database{Name: database.Name + "_drift"},
+		Create:     true,
+		TempSuffix: true,
+	}
+	reference, err := referenceAction.RunWithRootConn(ctx, rootConn, config)
+	if err != nil {
+		return nil, fmt.Errorf("Reference: %w", err)
+	}
+	defer func() {
+		_, err := dropDatabaseIfExists(ctx, rootConn, reference, config)
+		if err != nil {
+			config.warnDropFailed(reference.Name, err)
+		}
+	}()
+
+	for i, set := range sets {
+		err := runMigrateActionWithFactory(ctx, psqlmigrate.UpToAction(versions[i]), reference, config, set.factory, true)
+		if err != nil {
+			return nil, fmt.Errorf("Reference %s: %w", labels[i], err)
+		}
+	}
+
+	opts := config.withoutVersionTables(options)
+
+	expected, err := inspectDatabaseSchema(ctx, rootConn, reference, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Inspect reference: %w", err)
+	}
+
+	actual, err := inspectDatabaseSchema(ctx, rootConn, database, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Inspect: %w", err)
+	}
+
+	diff := db.DiffSchemas(expected, actual)
+	diff.FromLabel = strings.Join(labels, ",")
+	diff.ToLabel = database.Name
+
+	return diff, nil
+}
+
+func (a *DriftAction) Run(ctx context.Context, config *Config) (*db.SchemaDiff, error) {
+	rootConn, err := ConnectRootDB(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer rootConn.Close(ctx)
+
+	return a.RunWithRootConn(ctx, rootConn, config)
+}
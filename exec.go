@@ -50,7 +50,7 @@ func (a *ExecAction) cmd(ctx context.Context, database *db.Database, config *Con
 	connstr := config.ConnString.Copy()
 	connstr.Set("database", database.Name)
 	for _, v := range a.Opts.Conn.Parts {
-		fmt.Println("CONN", v)
+		config.logger().Debug("CONN "+v, "conn", v)
 		connstr.LoadConnString(v)
 	}
 
@@ -107,7 +107,7 @@ func (a *ExecAction) RunWithRootConn(ctx context.Context, rootConn *pgx.Conn, co
 		if !keep {
 			_, err := dropDatabaseIfExists(ctx, rootConn, database, config)
 			if err != nil {
-				fmt.Printf("\n\nWARNING! Failed to drop database \"%s\". You need to clean up by hand!\n   ERR: %v\n\n", database.Name, err)
+				config.warnDropFailed(database.Name, err)
 			}
 		}
 	}()
@@ -48,6 +48,8 @@ func (a *InitDatabaseAction) RunWithRootConn(ctx context.Context, rootConn *pgx.
 	if config == nil {
 		config = &GlobalConfig
 	}
+	ctx = config.logContext(ctx)
+	logger := config.logger()
 
 	if a.Database == nil {
 		a.Database = config.TargetDatabase()
@@ -60,6 +62,18 @@ func (a *InitDatabaseAction) RunWithRootConn(ctx context.Context, rootConn *pgx.
 	database := a.Database
 	dbName := database.Name
 
+	initRunner := config.InitRunner
+	initRunner.Vars = config.SqlVars(database)
+	initRunner.Database = dbName
+
+	// Cluster init
+	if initRunner.Cluster().Len() > 0 {
+		logger.Info("INITIALIZE CLUSTER", db.Phase("init"), "database", dbName)
+		if err := initRunner.RunCluster(ctx, rootConn); err != nil {
+			return database, fmt.Errorf("Failed InitDatabaseAction \"%s\": Cluster init: %w", dbName, err)
+		}
+	}
+
 	// Drop if exists
 	if a.DropIfExists {
 		_, err := dropDatabaseIfExists(ctx, rootConn, database, config)
@@ -79,12 +93,12 @@ func (a *InitDatabaseAction) RunWithRootConn(ctx context.Context, rootConn *pgx.
 			if !success {
 				err := a.Database.ForceDrop(ctx, rootConn)
 				if err != nil {
-					fmt.Printf("\n\nWARNING! Failed to drop database \"%s\". You need to clean up by hand!\n   ERR: %v\n\n", a.Database.Name, err)
+					config.warnDropFailed(a.Database.Name, err)
 				}
 			}
 		}()
 
-		fmt.Printf(">> Created database \"%s\".\n", dbName)
+		logger.Info(fmt.Sprintf("Created database \"%s\".", dbName), db.Phase("create"), "database", dbName)
 	}
 
 	// Connect
@@ -96,15 +110,24 @@ func (a *InitDatabaseAction) RunWithRootConn(ctx context.Context, rootConn *pgx.
 	}
 	defer conn.Close(ctx)
 
+	// Lock
+	if lock := config.migrationProviderFactory.MigrationLock(); lock != nil {
+		release, err := lock.Acquire(ctx, conn)
+		if err != nil {
+			return database, fmt.Errorf("Failed InitDatabaseAction \"%s\": Lock: %w", dbName, err)
+		}
+		defer release(context.WithoutCancel(ctx))
+	}
+
 	// Init
-	fmt.Println(">> INITIALIZE DATABASE")
-	if err := config.InitRunner.Run(ctx, conn); err != nil {
+	logger.Info("INITIALIZE DATABASE", db.Phase("init"), "database", dbName)
+	if err := initRunner.RunWithRoot(ctx, conn, rootConn); err != nil {
 		return database, fmt.Errorf("Failed InitDatabaseAction \"%s\": Init: %w", dbName, err)
 	}
 
 	// Migrate
 	if a.Migrate != nil {
-		if err := RunMigrateActionInDatabase(ctx, a.Migrate, database, config); err != nil {
+		if err := runMigrateActionInDatabase(ctx, a.Migrate, database, config, false); err != nil {
 			return database, fmt.Errorf("Failed InitDatabaseAction \"%s\": Migrate: %w", dbName, err)
 		}
 	}
@@ -119,3 +142,80 @@ func (a *InitDatabaseAction) RunWithRootConn(ctx context.Context, rootConn *pgx.
 	success = true
 	return database, nil
 }
+
+// RunInit runs the cluster init scripts and the init scripts on the existing
+// database. Uses the target database if database is nil.
+func RunInit(ctx context.Context, database *db.Database, config *Config) error {
+	return runInit(ctx, database, config, false)
+}
+
+// PlanInit evaluates the conditions of the cluster init scripts and the init
+// scripts on the existing database, and logs which of them would be applied
+// without applying them. Uses the target database if database is nil.
+func PlanInit(ctx context.Context, database *db.Database, config *Config) error {
+	return runInit(ctx, database, config, true)
+}
+
+func runInit(ctx context.Context, database *db.Database, config *Config, plan bool) error {
+	if config == nil {
+		config = &GlobalConfig
+	}
+	ctx = config.logContext(ctx)
+	logger := config.logger()
+	if database == nil {
+		database = config.TargetDatabase()
+	}
+	dbName := database.Name
+
+	rootConn, err := ConnectRootDB(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer rootConn.Close(ctx)
+
+	conn, err := connectTarget(ctx, database, config)
+	if err != nil {
+		return fmt.Errorf("Failed Init \"%s\": Failed to connect: %w", dbName, err)
+	}
+	defer conn.Close(ctx)
+
+	initRunner := config.InitRunner
+	initRunner.Vars = config.SqlVars(database)
+	initRunner.Database = dbName
+
+	if plan {
+		if initRunner.Cluster().Len() > 0 {
+			logger.Info("PLAN CLUSTER INIT", db.Phase("init"), "database", dbName)
+			if err := initRunner.PlanCluster(ctx, rootConn); err != nil {
+				return fmt.Errorf("Failed Init \"%s\": Cluster init: %w", dbName, err)
+			}
+		}
+
+		logger.Info("PLAN INIT", db.Phase("init"), "database", dbName)
+		if err := initRunner.Plan(ctx, conn, rootConn); err != nil {
+			return fmt.Errorf("Failed Init \"%s\": %w", dbName, err)
+		}
+		return nil
+	}
+
+	if lock := config.migrationProviderFactory.MigrationLock(); lock != nil {
+		release, err := lock.Acquire(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("Failed Init \"%s\": Lock: %w", dbName, err)
+		}
+		defer release(context.WithoutCancel(ctx))
+	}
+
+	if initRunner.Cluster().Len() > 0 {
+		logger.Info("INITIALIZE CLUSTER", db.Phase("init"), "database", dbName)
+		if err := initRunner.RunCluster(ctx, rootConn); err != nil {
+			return fmt.Errorf("Failed Init \"%s\": Cluster init: %w", dbName, err)
+		}
+	}
+
+	logger.Info("INITIALIZE DATABASE", db.Phase("init"), "database", dbName)
+	if err := initRunner.RunWithRoot(ctx, conn, rootConn); err != nil {
+		return fmt.Errorf("Failed Init \"%s\": %w", dbName, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package psqlinit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Query condition
+type queryCond struct {
+	description string
+	query       string
+	args        []any
+}
+
+// QueryReturnsRowsCond matches if the query returns at least one row.
+func QueryReturnsRowsCond(sql string, args ...any) Condition {
+	return &queryCond{
+		description: fmt.Sprintf("Query returns rows: %s", strings.Join(strings.Fields(sql), " ")),
+		query:       sql,
+		args:        args,
+	}
+}
+
+func (v *queryCond) Description() string {
+	return v.description
+}
+
+func (v *queryCond) Evaluate(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	var res bool
+	err := conn.QueryRow(ctx, "SELECT EXISTS ("+v.query+")", v.args...).Scan(&res)
+	return res, err
+}
+
+// splitQualifiedName splits an optionally schema-qualified name. The schema
+// is empty if the name is not qualified.
+func splitQualifiedName(name string) (schema string, local string) {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// ColumnExistsCond matches if the column exists. The name is of the form
+// `table.column` or `schema.table.column`.
+func ColumnExistsCond(name string) Condition {
+	table, column := splitQualifiedName(name)
+	if len(table) == 0 {
+		panic("Column name needs a table!")
+	}
+	schema, table := splitQualifiedName(table)
+
+	return &queryCond{
+		description: fmt.Sprintf("Column \"%s\" exists", name),
+		query: `SELECT FROM pg_catalog.pg_attribute a
+JOIN pg_catalog.pg_class c ON a.attrelid = c.oid
+JOIN pg_catalog.pg_namespace n ON c.relnamespace = n.oid
+WHERE ($1 = '' OR n.nspname = $1) AND c.relname = $2 AND a.attname = $3 AND a.attnum > 0 AND NOT a.attisdropped`,
+		args: []any{schema, table, column},
+	}
+}
+
+// TypeExistsCond matches if the (optionally schema-qualified) type exists.
+func TypeExistsCond(name string) Condition {
+	schema, typ := splitQualifiedName(name)
+
+	return &queryCond{
+		description: fmt.Sprintf("Type \"%s\" exists", name),
+		query: `SELECT FROM pg_catalog.pg_type t
+JOIN pg_catalog.pg_namespace n ON t.typnamespace = n.oid
+WHERE ($1 = '' OR n.nspname = $1) AND t.typname = $2`,
+		args: []any{schema, typ},
+	}
+}
+
+// EnumLabelExistsCond matches if the (optionally schema-qualified) enum type
+// has the label.
+func EnumLabelExistsCond(name string, label string) Condition {
+	schema, typ := splitQualifiedName(name)
+
+	return &queryCond{
+		description: fmt.Sprintf("Enum \"%s\" has label \"%s\"", name, label),
+		query: `SELECT FROM pg_catalog.pg_enum e
+JOIN pg_catalog.pg_type t ON e.enumtypid = t.oid
+JOIN pg_catalog.pg_namespace n ON t.typnamespace = n.oid
+WHERE ($1 = '' OR n.nspname = $1) AND t.typname = $2 AND e.enumlabel = $3`,
+		args: []any{schema, typ, label},
+	}
+}
+
+// FunctionExistsCond matches if the function exists. If the name contains
+// an argument list, like `public.add(integer, integer)`, only the function
+// with that signature matches.
+func FunctionExistsCond(signature string) Condition {
+	description := fmt.Sprintf("Function \"%s\" exists", signature)
+	if strings.Contains(signature, "(") {
+		return &queryCond{
+			description: description,
+			query:       `SELECT WHERE to_regprocedure($1) IS NOT NULL`,
+			args:        []any{signature},
+		}
+	}
+
+	schema, fn := splitQualifiedName(signature)
+	return &queryCond{
+		description: description,
+		query: `SELECT FROM pg_catalog.pg_proc p
+JOIN pg_catalog.pg_namespace n ON p.pronamespace = n.oid
+WHERE ($1 = '' OR n.nspname = $1) AND p.proname = $2`,
+		args: []any{schema, fn},
+	}
+}
+
+// TriggerExistsCond matches if the trigger exists on the (optionally
+// schema-qualified) table.
+func TriggerExistsCond(table string, trigger string) Condition {
+	return &queryCond{
+		description: fmt.Sprintf("Trigger \"%s\" on \"%s\" exists", trigger, table),
+		query: `SELECT FROM pg_catalog.pg_trigger
+WHERE tgrelid = to_regclass($1) AND tgname = $2 AND NOT tgisinternal`,
+		args: []any{table, trigger},
+	}
+}
+
+// PolicyExistsCond matches if the row level security policy exists on the
+// (optionally schema-qualified) table.
+func PolicyExistsCond(table string, policy string) Condition {
+	return &queryCond{
+		description: fmt.Sprintf("Policy \"%s\" on \"%s\" exists", policy, table),
+		query: `SELECT FROM pg_catalog.pg_policy
+WHERE polrelid = to_regclass($1) AND polname = $2`,
+		args: []any{table, policy},
+	}
+}
+
+// PublicationExistsCond matches if the logical replication publication
+// exists.
+func PublicationExistsCond(name string) Condition {
+	return &queryCond{
+		description: fmt.Sprintf("Publication \"%s\" exists", name),
+		query:       `SELECT FROM pg_catalog.pg_publication WHERE pubname = $1`,
+		args:        []any{name},
+	}
+}
+
+// SettingEqualsCond matches if the run-time setting has the value.
+func SettingEqualsCond(name string, value string) Condition {
+	return &queryCond{
+		description: fmt.Sprintf("Setting \"%s\" equals \"%s\"", name, value),
+		query:       `SELECT WHERE current_setting($1, true) = $2`,
+		args:        []any{name, value},
+	}
+}
+
+// RoleExistsCond matches if the role exists in the cluster.
+func RoleExistsCond(name string) Condition {
+	return &queryCond{
+		description: fmt.Sprintf("Role \"%s\" exists", name),
+		query:       `SELECT FROM pg_catalog.pg_roles WHERE rolname = $1`,
+		args:        []any{name},
+	}
+}
+
+// TablespaceExistsCond matches if the tablespace exists in the cluster.
+func TablespaceExistsCond(name string) Condition {
+	return &queryCond{
+		description: fmt.Sprintf("Tablespace \"%s\" exists", name),
+		query:       `SELECT FROM pg_catalog.pg_tablespace WHERE spcname = $1`,
+		args:        []any{name},
+	}
+}
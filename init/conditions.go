@@ -277,3 +277,180 @@ func (k RelKind) String() string {
 		panic(fmt.Sprintf("Unknown RelKind %d", k))
 	}
 }
+
+// Extensions exist condition
+type extensionExistsCond struct {
+	extensions []string
+}
+
+func ExtensionExistsCond(extensions ...string) Condition {
+	if len(extensions) == 0 {
+		panic("ExtensionExists condition needs at least one extension!")
+	}
+
+	return &extensionExistsCond{extensions}
+}
+
+func (v *extensionExistsCond) Description() string {
+	if len(v.extensions) == 1 {
+		return fmt.Sprintf("Extension \"%s\" is installed", v.extensions[0])
+	}
+
+	return fmt.Sprintf("One of extensions \"%s\" is installed", strings.Join(v.extensions, "\", \""))
+}
+
+func (v *extensionExistsCond) Evaluate(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	var res bool
+	err := conn.QueryRow(ctx, `
+SELECT EXISTS(
+  SELECT
+  FROM pg_catalog.pg_extension
+  WHERE extname = ANY($1)
+)
+`, v.extensions).Scan(&res)
+
+	return res, err
+}
+
+// CompositeCondition combines the results of other conditions. The Runner
+// logs the results of its operands nested below it.
+type CompositeCondition interface {
+	Condition
+	// Short description of how the operands are combined.
+	Label() string
+	Operands() []Condition
+	Combine(matches []bool) bool
+}
+
+// All condition
+type allCond struct {
+	conds []Condition
+}
+
+// AllCond matches if all of the conditions match.
+func AllCond(conds ...Condition) Condition {
+	if len(conds) == 0 {
+		panic("All condition needs at least one condition!")
+	}
+
+	return &allCond{conds}
+}
+
+func (v *allCond) Label() string {
+	return "All of"
+}
+
+func (v *allCond) Operands() []Condition {
+	return v.conds
+}
+
+func (v *allCond) Combine(matches []bool) bool {
+	for _, m := range matches {
+		if !m {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *allCond) Description() string {
+	return joinDescriptions(v.conds, " AND ")
+}
+
+func (v *allCond) Evaluate(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	for _, c := range v.conds {
+		matches, err := c.Evaluate(ctx, conn)
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Any condition
+type anyCond struct {
+	conds []Condition
+}
+
+// AnyCond matches if any of the conditions match.
+func AnyCond(conds ...Condition) Condition {
+	if len(conds) == 0 {
+		panic("Any condition needs at least one condition!")
+	}
+
+	return &anyCond{conds}
+}
+
+func (v *anyCond) Label() string {
+	return "Any of"
+}
+
+func (v *anyCond) Operands() []Condition {
+	return v.conds
+}
+
+func (v *anyCond) Combine(matches []bool) bool {
+	for _, m := range matches {
+		if m {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *anyCond) Description() string {
+	return joinDescriptions(v.conds, " OR ")
+}
+
+func (v *anyCond) Evaluate(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	for _, c := range v.conds {
+		matches, err := c.Evaluate(ctx, conn)
+		if err != nil || matches {
+			return matches, err
+		}
+	}
+	return false, nil
+}
+
+// Not condition
+type notCond struct {
+	cond Condition
+}
+
+// NotCond matches if the condition does not match.
+func NotCond(cond Condition) Condition {
+	return &notCond{cond}
+}
+
+func (v *notCond) Label() string {
+	return "Not"
+}
+
+func (v *notCond) Operands() []Condition {
+	return []Condition{v.cond}
+}
+
+func (v *notCond) Combine(matches []bool) bool {
+	return !matches[0]
+}
+
+func (v *notCond) Description() string {
+	return "NOT " + joinDescriptions([]Condition{v.cond}, "")
+}
+
+func (v *notCond) Evaluate(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	matches, err := v.cond.Evaluate(ctx, conn)
+	return !matches && err == nil, err
+}
+
+func joinDescriptions(conds []Condition, sep string) string {
+	parts := make([]string, len(conds))
+	for i, c := range conds {
+		parts[i] = c.Description()
+		switch c.(type) {
+		case *allCond, *anyCond:
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,229 @@
+package psqlinit
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// AddDir adds the `.sql` and `.tmpl` files in the directory as init scripts
+// in lexical order. The `.tmpl` files are rendered as templates, see
+// InitTemplateFile. The skip conditions and dependencies of each file are
+// read from the comments at the top of the file, like:
+//
+//	-- skip-when: schema-exists auth
+//	-- skip-when: table-exists public.users
+//	-- after: extensions.sql
+//
+// Files with a `-- no-transaction` comment are not applied in a transaction.
+func (s *Repository) AddDir(fsys fs.FS, dirpath ...string) (err error) {
+	if s == nil {
+		s = &globalRepository
+	}
+
+	if len(dirpath) > 0 {
+		fsys, err = fs.Sub(fsys, filepath.Join(dirpath...))
+		if err != nil {
+			return err
+		}
+	}
+
+	// ReadDir returns the entries sorted by filename.
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".sql" && ext != ".tmpl") {
+			continue
+		}
+
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return err
+		}
+
+		skipWhen, err := ParseHeaderConditions(string(contents))
+		if err != nil {
+			return fmt.Errorf("Init file '%s': %w", entry.Name(), err)
+		}
+
+		script := InitSqlFile(fsys, entry.Name())
+		if ext == ".tmpl" {
+			script = InitTemplateFile(fsys, entry.Name())
+		}
+		after, err := ParseHeaderDependencies(string(contents))
+		if err != nil {
+			return fmt.Errorf("Init file '%s': %w", entry.Name(), err)
+		}
+		if len(after) > 0 {
+			script = After(script, after...)
+		}
+		noTransaction, err := headerAnnotations(string(contents), "no-transaction")
+		if err != nil {
+			return fmt.Errorf("Init file '%s': %w", entry.Name(), err)
+		}
+		if len(noTransaction) > 0 {
+			script = NoTransaction(script)
+		}
+
+		s.Add(script, skipWhen...)
+	}
+
+	return nil
+}
+
+func AddDir(fsys fs.FS, dirpath ...string) error {
+	return globalRepository.AddDir(fsys, dirpath...)
+}
+
+// ParseHeaderConditions parses the `-- skip-when:` annotations in the
+// comments at the top of the SQL.
+func ParseHeaderConditions(sql string) ([]Condition, error) {
+	values, err := headerAnnotations(sql, "skip-when:")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Condition
+	for _, value := range values {
+		cond, err := ParseCondition(value)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, cond)
+	}
+	return res, nil
+}
+
+// ParseHeaderDependencies parses the `-- after:` annotations in the comments
+// at the top of the SQL. Each annotation lists one or more init script names,
+// separated by commas or spaces.
+func ParseHeaderDependencies(sql string) ([]string, error) {
+	values, err := headerAnnotations(sql, "after:")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, value := range values {
+		res = append(res, strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})...)
+	}
+	return res, nil
+}
+
+// headerAnnotations returns the values of the `-- KEY` comments at the top of
+// the SQL.
+func headerAnnotations(sql string, key string) ([]string, error) {
+	var res []string
+
+	scanner := bufio.NewScanner(strings.NewReader(sql))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		comment, ok := strings.CutPrefix(line, "--")
+		if !ok {
+			break
+		}
+
+		value, ok := strings.CutPrefix(strings.TrimSpace(comment), key)
+		if ok {
+			res = append(res, value)
+		}
+	}
+
+	return res, scanner.Err()
+}
+
+// ParseCondition parses a condition like `schema-exists auth`,
+// `table-exists public.users` or `setting-equals wal_level logical`. Prefix
+// it with `not` to negate it.
+func ParseCondition(value string) (Condition, error) {
+	fields := strings.Fields(value)
+	if len(fields) > 0 && fields[0] == "not" {
+		cond, err := ParseCondition(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, err
+		}
+		return NotCond(cond), nil
+	}
+
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("Invalid condition '%s': expected KIND NAME", strings.TrimSpace(value))
+	}
+
+	kind, args := fields[0], fields[1:]
+	switch kind {
+	case "schema-exists":
+		return SchemaExistsCond(args...), nil
+	case "extension-exists":
+		return ExtensionExistsCond(args...), nil
+	case "function-exists":
+		return FunctionExistsCond(strings.Join(args, " ")), nil
+	case "trigger-exists", "policy-exists", "enum-label-exists", "setting-equals":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Invalid condition '%s': %s takes two arguments", strings.TrimSpace(value), kind)
+		}
+		switch kind {
+		case "trigger-exists":
+			return TriggerExistsCond(args[0], args[1]), nil
+		case "policy-exists":
+			return PolicyExistsCond(args[0], args[1]), nil
+		case "enum-label-exists":
+			return EnumLabelExistsCond(args[0], args[1]), nil
+		default:
+			return SettingEqualsCond(args[0], args[1]), nil
+		}
+	}
+
+	if len(args) > 1 {
+		return nil, fmt.Errorf("Invalid condition '%s': %s takes a single name", strings.TrimSpace(value), kind)
+	}
+	switch kind {
+	case "column-exists":
+		if !strings.Contains(args[0], ".") {
+			return nil, fmt.Errorf("Invalid condition '%s': column name needs a table", strings.TrimSpace(value))
+		}
+		return ColumnExistsCond(args[0]), nil
+	case "type-exists":
+		return TypeExistsCond(args[0]), nil
+	case "publication-exists":
+		return PublicationExistsCond(args[0]), nil
+	case "role-exists":
+		return RoleExistsCond(args[0]), nil
+	case "tablespace-exists":
+		return TablespaceExistsCond(args[0]), nil
+	}
+
+	if strings.Count(args[0], ".") > 1 {
+		return nil, fmt.Errorf("Invalid condition '%s': relation name with more than 2 parts", strings.TrimSpace(value))
+	}
+
+	switch kind {
+	case "relation-exists":
+		return RelExistsCond(args[0], ANY), nil
+	case "table-exists":
+		return TableExistsCond(args[0]), nil
+	case "view-exists":
+		return ViewExistsCond(args[0]), nil
+	case "table-or-view-exists":
+		return TableOrViewExistsCond(args[0]), nil
+	case "sequence-exists":
+		return SequenceExistsCond(args[0]), nil
+	case "index-exists":
+		return IndexExistsCond(args[0]), nil
+	default:
+		return nil, fmt.Errorf("Unknown condition '%s'", kind)
+	}
+}
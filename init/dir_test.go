@@ -0,0 +1,99 @@
+package psqlinit
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHeaderAnnotationsScanError(t *testing.T) {
+	sql := "-- " + strings.Repeat("x", bufio.MaxScanTokenSize) + "\n-- skip-when: schema-exists auth\n"
+
+	if _, err := ParseHeaderConditions(sql); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected ErrTooLong, got %v", err)
+	}
+	if _, err := ParseHeaderDependencies(sql); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestParseHeaderConditions(t *testing.T) {
+	sql := `-- Creates the auth schema.
+-- skip-when: schema-exists auth
+--   skip-when: table-exists public.users
+-- skip-when: not extension-exists pgcrypto
+-- skip-when: function-exists public.add(integer, integer)
+-- skip-when: setting-equals wal_level logical
+
+CREATE SCHEMA auth;
+-- skip-when: view-exists ignored
+`
+
+	conds, err := ParseHeaderConditions(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`Schema "auth" exists`,
+		`Relation "public.users" of kind AnyTable exists`,
+		`NOT Extension "pgcrypto" is installed`,
+		`Function "public.add(integer, integer)" exists`,
+		`Setting "wal_level" equals "logical"`,
+	}
+	if len(conds) != len(expected) {
+		t.Fatalf("Expected %d conditions, got %d", len(expected), len(conds))
+	}
+	for i, c := range conds {
+		if c.Description() != expected[i] {
+			t.Errorf("Condition %d: got %q, expected %q", i, c.Description(), expected[i])
+		}
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	for _, value := range []string{"", "schema-exists", "table-exists a b", "table-exists a.b.c", "unknown x", "not", "column-exists email", "trigger-exists users"} {
+		if _, err := ParseCondition(value); err == nil {
+			t.Errorf("Expected error for condition %q", value)
+		}
+	}
+}
+
+func TestCompositeConditionDescriptions(t *testing.T) {
+	cond := AllCond(
+		SchemaExistsCond("auth"),
+		NotCond(AnyCond(TableExistsCond("a"), ExtensionExistsCond("x"))),
+	)
+
+	expected := `Schema "auth" exists AND NOT (Relation "a" of kind AnyTable exists OR Extension "x" is installed)`
+	if cond.Description() != expected {
+		t.Errorf("got %q, expected %q", cond.Description(), expected)
+	}
+
+	composite := cond.(CompositeCondition)
+	if composite.Combine([]bool{true, false}) || !composite.Combine([]bool{true, true}) {
+		t.Error("AllCond should only match if all operands match")
+	}
+}
+
+func TestAddDirNoTransaction(t *testing.T) {
+	fsys := fstest.MapFS{
+		"01_index.sql": {Data: []byte("-- no-transaction\nCREATE INDEX CONCURRENTLY i ON t (c);\n")},
+		"02_table.sql": {Data: []byte("CREATE TABLE t ();\n")},
+	}
+
+	repo := &Repository{}
+	if err := repo.AddDir(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := repo.steps()
+	if !IsNoTransaction(steps[0].script) || IsNoTransaction(steps[1].script) {
+		t.Errorf("Expected only the first script to run without a transaction")
+	}
+	if err := checkSingleTransaction(steps); err == nil {
+		t.Errorf("Expected an error for a single transaction")
+	}
+}
@@ -0,0 +1,110 @@
+package psqlinit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type afterScript struct {
+	wrappedScript
+	dependencies []string
+}
+
+// After wraps the script such that it runs after the init scripts with the
+// names, regardless of the order in which they were added.
+func After(script InitScript, names ...string) InitScript {
+	return &afterScript{wrappedScript{script}, names}
+}
+
+// ScriptDependencies returns the names of the init scripts after which the
+// script runs.
+func ScriptDependencies(script InitScript) []string {
+	var res []string
+	for script != nil {
+		if a, ok := script.(*afterScript); ok {
+			res = append(res, a.dependencies...)
+		}
+		wrapped, ok := script.(WrappedInitScript)
+		if !ok {
+			break
+		}
+		script = wrapped.Unwrap()
+	}
+	return res
+}
+
+// orderSteps sorts the steps such that each step runs after its
+// dependencies. Otherwise, the steps keep the order in which they were added.
+func orderSteps(steps []initStep) ([]initStep, error) {
+	byName := make(map[string][]int)
+	for i, step := range steps {
+		byName[step.script.Name()] = append(byName[step.script.Name()], i)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(steps))
+	res := make([]initStep, 0, len(steps))
+	var path []string
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			start := slices.Index(path, steps[i].script.Name())
+			cycle := append(slices.Clone(path[start:]), steps[i].script.Name())
+			return fmt.Errorf("Dependency cycle in init scripts: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[i] = visiting
+		path = append(path, steps[i].script.Name())
+
+		for _, dep := range ScriptDependencies(steps[i].script) {
+			indices, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("InitScript '%s' runs after unknown init script '%s'", steps[i].script.Name(), dep)
+			}
+			for _, j := range indices {
+				if j == i {
+					return fmt.Errorf("InitScript '%s' runs after itself", dep)
+				}
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[i] = visited
+		res = append(res, steps[i])
+		return nil
+	}
+
+	for i := range steps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// Ordered returns the init scripts in the order in which they run.
+func (s *Repository) Ordered() ([]InitScript, error) {
+	steps, err := orderSteps(s.steps())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]InitScript, len(steps))
+	for i, step := range steps {
+		res[i] = step.script
+	}
+	return res, nil
+}
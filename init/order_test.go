@@ -0,0 +1,85 @@
+package psqlinit
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func orderedNames(t *testing.T, repo *Repository) []string {
+	t.Helper()
+
+	scripts, err := repo.Ordered()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(scripts))
+	for i, s := range scripts {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+func TestOrderedRespectsDependencies(t *testing.T) {
+	repo := &Repository{}
+	repo.Add(After(InitSql("tables", ""), "schemas", "extensions"))
+	repo.Add(InitSql("schemas", ""))
+	repo.Add(InitSql("roles", ""))
+	repo.Add(After(InitSql("extensions", ""), "schemas"))
+
+	names := orderedNames(t, repo)
+	expected := []string{"schemas", "extensions", "tables", "roles"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("got %v, expected %v", names, expected)
+	}
+}
+
+func TestOrderedKeepsInsertionOrder(t *testing.T) {
+	repo := &Repository{}
+	repo.Add(InitSql("a", ""))
+	repo.Add(InitSql("b", ""))
+	repo.Add(OnRoot(After(InitSql("c", ""), "a")))
+
+	names := orderedNames(t, repo)
+	expected := []string{"a", "b", "c"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("got %v, expected %v", names, expected)
+	}
+}
+
+func TestOrderedErrors(t *testing.T) {
+	cycle := &Repository{}
+	cycle.Add(After(InitSql("a", ""), "c"))
+	cycle.Add(After(InitSql("b", ""), "a"))
+	cycle.Add(After(InitSql("c", ""), "b"))
+
+	_, err := cycle.Ordered()
+	if err == nil || !strings.Contains(err.Error(), "a -> c -> b -> a") {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+
+	unknown := &Repository{}
+	unknown.Add(After(InitSql("a", ""), "missing"))
+
+	_, err = unknown.Ordered()
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected unknown dependency error, got %v", err)
+	}
+}
+
+func TestParseHeaderDependencies(t *testing.T) {
+	sql := `-- after: extensions.sql, schemas.sql
+-- after: roles.sql
+CREATE TABLE users ();
+`
+
+	deps, err := ParseHeaderDependencies(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"extensions.sql", "schemas.sql", "roles.sql"}
+	if !slices.Equal(deps, expected) {
+		t.Errorf("got %v, expected %v", deps, expected)
+	}
+}
@@ -19,6 +19,25 @@ type initStep struct {
 
 var globalRepository = Repository{}
 
+var globalClusterRepository = Repository{}
+
+// AddCluster adds a script to the global cluster repository. Cluster init
+// scripts run on the root database before the target database is created.
+// They run without a transaction.
+func AddCluster(script InitScript, skipWhen ...Condition) {
+	globalClusterRepository.Add(script, skipWhen...)
+}
+
+// AddClusterSql adds a SQL script to the global cluster repository.
+func AddClusterSql(name string, sql string, skipWhen ...Condition) {
+	globalClusterRepository.AddSql(name, sql, skipWhen...)
+}
+
+// Len returns the number of init scripts in the repository.
+func (s *Repository) Len() int {
+	return len(s.steps())
+}
+
 func (s *Repository) Copy() *Repository {
 	if s == nil {
 		s = &globalRepository
@@ -62,6 +81,17 @@ func AddSqlFile(fs fs.FS, filename string, skipWhen ...Condition) {
 	globalRepository.AddSqlFile(fs, filename, skipWhen...)
 }
 
+func (s *Repository) AddTemplateFile(fs fs.FS, filename string, skipWhen ...Condition) {
+	if s == nil {
+		s = &globalRepository
+	}
+	s.Add(InitTemplateFile(fs, filename), skipWhen...)
+}
+
+func AddTemplateFile(fs fs.FS, filename string, skipWhen ...Condition) {
+	globalRepository.AddTemplateFile(fs, filename, skipWhen...)
+}
+
 func (s *Repository) AddFn(name string, impl func(context.Context, *pgx.Conn) error, skipWhen ...Condition) {
 	if s == nil {
 		s = &globalRepository
@@ -115,13 +145,39 @@ type initCondResult struct {
 	cond    Condition
 	matches bool
 	err     error
+	// Results of the operands of a CompositeCondition.
+	operands []initCondResult
+}
+
+// evalCondition evaluates the condition. Evaluates all operands of composite
+// conditions, such that their results can be logged.
+func evalCondition(ctx context.Context, conn *pgx.Conn, c Condition) initCondResult {
+	composite, ok := c.(CompositeCondition)
+	if !ok {
+		matches, err := c.Evaluate(ctx, conn)
+		return initCondResult{cond: c, matches: matches, err: err}
+	}
+
+	res := initCondResult{cond: c}
+	matches := make([]bool, 0, len(composite.Operands()))
+	for _, operand := range composite.Operands() {
+		r := evalCondition(ctx, conn, operand)
+		res.operands = append(res.operands, r)
+		if r.err != nil {
+			res.err = r.err
+			return res
+		}
+		matches = append(matches, r.matches)
+	}
+
+	res.matches = composite.Combine(matches)
+	return res
 }
 
 func (k *initStep) evalConditions(ctx context.Context, conn *pgx.Conn) []initCondResult {
 	res := make([]initCondResult, len(k.skipWhen))
 	for i, c := range k.skipWhen {
-		matches, err := c.Evaluate(ctx, conn)
-		res[i] = initCondResult{c, matches, err}
+		res[i] = evalCondition(ctx, conn, c)
 	}
 	return res
 }
@@ -129,8 +185,9 @@ func (k *initStep) evalConditions(ctx context.Context, conn *pgx.Conn) []initCon
 func (k *initStep) evalConditionsTillFirstMatch(ctx context.Context, conn *pgx.Conn) ([]initCondResult, bool, error) {
 	res := make([]initCondResult, 0, len(k.skipWhen))
 	for _, c := range k.skipWhen {
-		matches, err := c.Evaluate(ctx, conn)
-		res = append(res, initCondResult{c, matches, err})
+		r := evalCondition(ctx, conn, c)
+		res = append(res, r)
+		matches, err := r.matches, r.err
 		if err != nil {
 			return res, false, fmt.Errorf("condition '%s': %v", c.Description(), err)
 		}
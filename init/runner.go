@@ -2,24 +2,66 @@ package psqlinit
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
 )
 
 type Runner struct {
 	*Repository
 
+	// Init scripts that run on the root database before the target database
+	// is created. Uses the global cluster repository if nil.
+	ClusterRepository *Repository
+
 	LogLevel         LogLevel
 	IgnoreConditions bool
+
+	// Substitutes the placeholders in the SQL of ExpandingInitScripts.
+	Vars *db.Vars
+
+	// Name of the target database. Init script templates get it as
+	// .Database, also when they run on the root database. Defaults to the
+	// database of the connection.
+	Database string
+
+	// Timeouts of the session while the init scripts run.
+	Timeouts db.SessionTimeouts
+
+	// Table in which the applied init scripts are tracked with their
+	// checksums. Init scripts that were applied with the same checksum are
+	// skipped. Tracking is disabled if empty.
+	TrackingTable string
+
+	// What to do with tracked init scripts that changed since they were
+	// applied.
+	OnChanged ChangedScriptMode
+
+	// How the init scripts are wrapped in transactions.
+	TransactionMode TransactionMode
+
+	// Transaction of the init phase in SINGLE_TRANSACTION mode.
+	tx pgx.Tx
 }
 
 var GlobalRunner = Runner{LogLevel: NAMES_AND_EVALUATED_CONDITIONS}
 
 func (r *Runner) runScript(ctx context.Context, conn *pgx.Conn, script InitScript) (dur time.Duration, err error) {
+	if len(r.Database) > 0 {
+		ctx = withTargetDatabase(ctx, r.Database)
+	}
+
 	tic := time.Now()
-	err = script.Apply(ctx, conn)
+	if s, ok := script.(ExpandingInitScript); ok && r.Vars != nil {
+		err = s.ApplyExpanded(ctx, conn, r.Vars)
+	} else {
+		err = script.Apply(ctx, conn)
+	}
 	toc := time.Now()
 
 	dur = toc.Sub(tic)
@@ -27,23 +69,159 @@ func (r *Runner) runScript(ctx context.Context, conn *pgx.Conn, script InitScrip
 	return
 }
 
+// Cluster returns the repository of the init scripts that run on the root
+// database before the target database is created.
+func (r *Runner) Cluster() *Repository {
+	if r == nil || r.ClusterRepository == nil {
+		return &globalClusterRepository
+	}
+	return r.ClusterRepository
+}
+
+// RunCluster applies the cluster init scripts on the root database. Cluster
+// init scripts are not tracked.
+func (r *Runner) RunCluster(ctx context.Context, rootConn *pgx.Conn) (err error) {
+	if r == nil {
+		r = &GlobalRunner
+	}
+
+	resetTimeouts, err := r.Timeouts.Apply(ctx, rootConn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, resetTimeouts(ctx))
+	}()
+
+	steps, err := orderSteps(r.Cluster().steps())
+	if err != nil {
+		return err
+	}
+
+	return r.runSteps(ctx, rootConn, rootConn, steps, nil, false)
+}
+
+// Run applies the init scripts on the target database. Fails on init scripts
+// that have to run on the root database.
 func (r *Runner) Run(ctx context.Context, conn *pgx.Conn) error {
-	var err error
+	return r.RunWithRoot(ctx, conn, nil)
+}
+
+// RunWithRoot applies the init scripts on the target database, and the
+// init scripts wrapped with OnRoot on the root database.
+func (r *Runner) RunWithRoot(ctx context.Context, conn *pgx.Conn, rootConn *pgx.Conn) (err error) {
+	if r == nil {
+		r = &GlobalRunner
+	}
+
+	steps, err := orderSteps(r.Repository.steps())
+	if err != nil {
+		return err
+	}
+
+	resetTimeouts, err := r.Timeouts.Apply(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, resetTimeouts(ctx))
+	}()
+
+	var tracked map[string]string
+	if len(r.TrackingTable) > 0 {
+		tracked, err = r.readTracked(ctx, conn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.TransactionMode == SINGLE_TRANSACTION {
+		if err := checkSingleTransaction(steps); err != nil {
+			return err
+		}
+		return r.runInTx(ctx, conn, func(conn *pgx.Conn) error {
+			return r.runSteps(ctx, conn, rootConn, steps, tracked, false)
+		})
+	}
+
+	return r.runSteps(ctx, conn, rootConn, steps, tracked, false)
+}
 
+// PlanCluster evaluates the conditions of the cluster init scripts on the
+// root database and logs which of them would be applied, without applying
+// them.
+func (r *Runner) PlanCluster(ctx context.Context, rootConn *pgx.Conn) error {
 	if r == nil {
 		r = &GlobalRunner
 	}
 
+	steps, err := orderSteps(r.Cluster().steps())
+	if err != nil {
+		return err
+	}
+
+	return r.runSteps(ctx, rootConn, rootConn, steps, nil, true)
+}
+
+// Plan evaluates the conditions of the init scripts and logs which of them
+// would be applied, without applying them. Steps after the first step that
+// would be applied are always considered pending, because the conditions
+// depend on the changes of that step.
+func (r *Runner) Plan(ctx context.Context, conn *pgx.Conn, rootConn *pgx.Conn) (err error) {
+	if r == nil {
+		r = &GlobalRunner
+	}
+
+	steps, err := orderSteps(r.Repository.steps())
+	if err != nil {
+		return err
+	}
+
+	var tracked map[string]string
+	if len(r.TrackingTable) > 0 {
+		tracked, err = r.readTrackedIfExists(ctx, conn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.runSteps(ctx, conn, rootConn, steps, tracked, true)
+}
+
+// runSteps applies the steps. Only evaluates the conditions of all steps if
+// plan is set.
+func (r *Runner) runSteps(ctx context.Context, conn *pgx.Conn, rootConn *pgx.Conn, steps []initStep, tracked map[string]string, plan bool) (err error) {
 	alwaysRun := r.IgnoreConditions
 
-	for _, step := range r.Repository.steps() {
+	for _, step := range steps {
 		// Initialize report for later logging.
 		rep := runReport{step: &step}
 
+		stepConn := conn
+		if IsOnRoot(step.script) {
+			if rootConn == nil {
+				return fmt.Errorf("InitScript '%s' runs on the root database, but no root connection was given", step.script.Name())
+			}
+			stepConn = rootConn
+		}
+
+		// Skip the step if it was already applied with the same content.
+		var checksum string
+		if tracked != nil {
+			var unchanged bool
+			checksum, unchanged, rep.changed, err = r.trackedStatus(step.script, tracked, plan)
+			if err != nil {
+				return err
+			}
+			rep.tracked = unchanged && !r.IgnoreConditions
+		}
+
 		// Evaluate conditions to determine if the init step should be skipped.
 		skip := false
-		if r.LogLevel == NAMES_AND_ALL_CONDITIONS {
-			rep.conditions = step.evalConditions(ctx, conn)
+		if rep.tracked {
+			skip = true
+		} else if plan || r.LogLevel == NAMES_AND_ALL_CONDITIONS {
+			rep.conditions = step.evalConditions(ctx, stepConn)
 			for _, c := range rep.conditions {
 				if c.err != nil {
 					return fmt.Errorf("InitScript '%s': condition '%s': %v",
@@ -60,7 +238,7 @@ func (r *Runner) Run(ctx context.Context, conn *pgx.Conn) error {
 				}
 			}
 		} else if !alwaysRun {
-			rep.conditions, skip, err = step.evalConditionsTillFirstMatch(ctx, conn)
+			rep.conditions, skip, err = step.evalConditionsTillFirstMatch(ctx, stepConn)
 			if err != nil {
 				return fmt.Errorf("InitScript '%s': %v", step.script.Name(), err)
 			}
@@ -69,22 +247,44 @@ func (r *Runner) Run(ctx context.Context, conn *pgx.Conn) error {
 		// Apply or skip step
 		if skip {
 			rep.status = SKIPPED
+		} else if plan {
+			rep.status = PENDING
+			alwaysRun = true
 		} else {
-			rep.duration, err = r.runScript(ctx, conn, step.script)
+			// Record the step in the same transaction if it runs on the
+			// connection with the tracking table.
+			record := func(c *pgx.Conn) error {
+				if tracked == nil || stepConn != conn {
+					return nil
+				}
+				return r.recordApplied(ctx, c, step.script.Name(), checksum)
+			}
+
+			rep.duration, err = r.applyStep(ctx, stepConn, step.script, stepConn == rootConn, record)
 			if err != nil {
 				rep.status = FAILED
 				return fmt.Errorf("InitScript.Apply '%s': %v", step.script.Name(), err)
 			}
 			rep.status = APPLIED
 			alwaysRun = true
+
+			if tracked != nil && stepConn != conn {
+				if err := r.recordApplied(ctx, conn, step.script.Name(), checksum); err != nil {
+					return err
+				}
+			}
 		}
 
 		// Log Results
 		if r.LogLevel >= NAMES_ONLY {
-			fmt.Printf("    %s\n", rep.String())
+			attrs := []any{db.Indent(1), db.StatusKey, rep.status.String(), "script", step.script.Name()}
+			if rep.status != SKIPPED && rep.status != PENDING {
+				attrs = append(attrs, db.DurationKey, rep.duration)
+			}
+			db.Logger(ctx).Info(rep.message(plan), attrs...)
 		}
 		if r.LogLevel >= NAMES_AND_EVALUATED_CONDITIONS {
-			rep.printConditions()
+			rep.logConditions(db.Logger(ctx))
 		}
 	}
 
@@ -107,6 +307,7 @@ const (
 	APPLIED
 	SKIPPED
 	FAILED
+	PENDING
 )
 
 func (s InitStepStatus) String() string {
@@ -119,6 +320,8 @@ func (s InitStepStatus) String() string {
 		return "SKIPPED"
 	case FAILED:
 		return "FAILED"
+	case PENDING:
+		return "PENDING"
 	default:
 		panic("Unknown InitStepStatus")
 	}
@@ -130,27 +333,37 @@ type runReport struct {
 	status     InitStepStatus
 	duration   time.Duration
 	conditions []initCondResult
+	// Skipped because it was applied with the same checksum before.
+	tracked bool
+	// Changed since it was applied before.
+	changed bool
 }
 
-func (s *runReport) String() string {
-	if s.status == SKIPPED {
-		return fmt.Sprintf(
-			"%-6s %-40s",
-			&s.status,
-			s.step.script.Name(),
-		)
-	} else {
-		return fmt.Sprintf(
-			"%-5s %-40s (%6.2fms)",
-			&s.status,
-			s.step.script.Name(),
-			s.duration.Seconds()*1000,
-		)
+// message returns the name of the script with notes about its tracking and,
+// in plan mode, about where and after which scripts it runs.
+func (s *runReport) message(plan bool) string {
+	res := s.step.script.Name()
+	if s.tracked {
+		res += " (already applied)"
+	} else if s.changed {
+		res += " (changed)"
+	}
+
+	if plan && IsOnRoot(s.step.script) {
+		res += " (on root)"
+	}
+	if deps := ScriptDependencies(s.step.script); plan && len(deps) > 0 {
+		res += " (after " + strings.Join(deps, ", ") + ")"
 	}
+	return res
 }
 
-func (s *runReport) printConditions() {
-	for _, c := range s.conditions {
+func (s *runReport) logConditions(logger *slog.Logger) {
+	logConditionResults(logger, s.conditions, 1)
+}
+
+func logConditionResults(logger *slog.Logger, results []initCondResult, indent int) {
+	for _, c := range results {
 		var prefix rune
 		if c.err != nil {
 			prefix = '!'
@@ -160,9 +373,22 @@ func (s *runReport) printConditions() {
 			prefix = '✗'
 		}
 
-		fmt.Printf("    %s %s\n", string(prefix), c.cond.Description())
+		if composite, ok := c.cond.(CompositeCondition); ok {
+			logger.Info(fmt.Sprintf("%s %s:", string(prefix), composite.Label()), db.Indent(indent))
+			logConditionResults(logger, c.operands, indent+1)
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("%s %s", string(prefix), c.cond.Description()),
+			db.Indent(indent),
+			"matches", c.matches,
+		)
 		if c.err != nil {
-			fmt.Printf("        %v\n", c.err)
+			logger.Warn(c.err.Error(),
+				db.Indent(indent+1),
+				"condition", c.cond.Description(),
+				"error", c.err,
+			)
 		}
 	}
 }
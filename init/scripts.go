@@ -13,6 +13,13 @@ type InitScript interface {
 	Apply(ctx context.Context, conn *pgx.Conn) error
 }
 
+// ExpandingInitScript is an InitScript with SQL that can contain `${NAME}`
+// placeholders. The Runner applies it with its variables if it has any.
+type ExpandingInitScript interface {
+	InitScript
+	ApplyExpanded(ctx context.Context, conn *pgx.Conn, vars *db.Vars) error
+}
+
 // Init SQL
 type initSql struct {
 	name string
@@ -54,11 +61,19 @@ func (v *initSqlFile) String() string {
 }
 
 func (v *initSqlFile) Apply(ctx context.Context, conn *pgx.Conn) error {
+	return v.ApplyExpanded(ctx, conn, nil)
+}
+
+func (v *initSqlFile) ApplyExpanded(ctx context.Context, conn *pgx.Conn, vars *db.Vars) error {
 	contents, err := fs.ReadFile(v.fs, v.filename)
 	if err != nil {
 		return err
 	}
-	sql := string(contents)
+
+	sql, err := vars.Expand(string(contents))
+	if err != nil {
+		return err
+	}
 
 	_, err = conn.Exec(ctx, sql)
 	if err != nil {
@@ -0,0 +1,173 @@
+package psqlinit
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+// TemplateData is the data with which init script templates are rendered.
+type TemplateData struct {
+	// Name of the target database of the runner, also if the script is
+	// applied on the root database.
+	Database string
+	// Name of the database on which the script is applied.
+	ConnectedDatabase string
+	// User that is connected to the database.
+	User string
+	// Settings of the connection, like host, port and application_name.
+	// Does not contain the password.
+	Settings map[string]string
+	// Server version, like "16.2".
+	ServerVersion string
+	// Server version as a number, like 160002.
+	ServerVersionNum int
+	// Variables of the runner. See WithSqlVars.
+	Vars map[string]string
+}
+
+// TemplateFuncs are the functions that are available in init script
+// templates:
+//
+//	{{ ident .User }}            "my-user"
+//	{{ ident "auth" "users" }}   "auth"."users"
+//	{{ literal .Database }}      'my-database'
+var TemplateFuncs = template.FuncMap{
+	"ident":   QuoteIdentifier,
+	"literal": QuoteLiteral,
+}
+
+// QuoteIdentifier quotes the parts of a (qualified) identifier.
+func QuoteIdentifier(parts ...string) string {
+	return pgx.Identifier(parts).Sanitize()
+}
+
+// QuoteLiteral quotes the value as a string literal.
+func QuoteLiteral(value string) string {
+	if strings.Contains(value, `\`) {
+		return `E'` + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(value) + `'`
+	}
+	return `'` + strings.ReplaceAll(value, `'`, `''`) + `'`
+}
+
+type targetDatabaseKey struct{}
+
+// withTargetDatabase returns a copy of the context that carries the name of
+// the target database of the runner.
+func withTargetDatabase(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, targetDatabaseKey{}, name)
+}
+
+// targetDatabase returns the name of the target database of the context, or
+// the connected database if the context has none.
+func targetDatabase(ctx context.Context, connected string) string {
+	if name, ok := ctx.Value(targetDatabaseKey{}).(string); ok {
+		return name
+	}
+	return connected
+}
+
+// NewTemplateData reads the template data from the connection. The target
+// database is read from the context if the script runs in a Runner, and is
+// the database of the connection otherwise.
+func NewTemplateData(ctx context.Context, conn *pgx.Conn, vars *db.Vars) (*TemplateData, error) {
+	config := conn.Config()
+
+	settings := make(map[string]string, len(config.RuntimeParams)+4)
+	maps.Copy(settings, config.RuntimeParams)
+	settings["host"] = config.Host
+	settings["port"] = strconv.Itoa(int(config.Port))
+	settings["user"] = config.User
+	settings["dbname"] = config.Database
+
+	var versionNum string
+	if err := conn.QueryRow(ctx, "SHOW server_version_num").Scan(&versionNum); err != nil {
+		return nil, fmt.Errorf("Failed to read server version: %w", err)
+	}
+	num, err := strconv.Atoi(versionNum)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid server version '%s': %w", versionNum, err)
+	}
+
+	version, _, _ := strings.Cut(conn.PgConn().ParameterStatus("server_version"), " ")
+
+	data := &TemplateData{
+		Database:          targetDatabase(ctx, config.Database),
+		ConnectedDatabase: config.Database,
+		User:              config.User,
+		Settings:          settings,
+		ServerVersion:     version,
+		ServerVersionNum:  num,
+		Vars:              make(map[string]string),
+	}
+	if vars != nil {
+		maps.Copy(data.Vars, vars.Values)
+	}
+	return data, nil
+}
+
+// renderTemplate renders the SQL template. Missing keys are an error.
+func renderTemplate(name string, source string, data *TemplateData) (string, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(TemplateFuncs).
+		Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Init SQL Template File
+type initTemplateFile struct {
+	initSqlFile
+}
+
+func (v *initTemplateFile) Apply(ctx context.Context, conn *pgx.Conn) error {
+	return v.ApplyExpanded(ctx, conn, nil)
+}
+
+func (v *initTemplateFile) ApplyExpanded(ctx context.Context, conn *pgx.Conn, vars *db.Vars) error {
+	contents, err := fs.ReadFile(v.fs, v.filename)
+	if err != nil {
+		return err
+	}
+
+	data, err := NewTemplateData(ctx, conn, vars)
+	if err != nil {
+		return err
+	}
+
+	sql, err := renderTemplate(v.filename, string(contents), data)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(ctx, sql)
+	if err != nil {
+		return db.ErrWithPgRowCol(err, v.filename, sql)
+	}
+
+	return nil
+}
+
+// InitTemplateFile is an init script with SQL that is rendered as a Go
+// text/template with TemplateData and TemplateFuncs.
+func InitTemplateFile(fs fs.FS, filename string) InitScript {
+	return &initTemplateFile{initSqlFile{
+		fs:       fs,
+		filename: filename,
+	}}
+}
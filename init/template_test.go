@@ -0,0 +1,67 @@
+package psqlinit
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	data := &TemplateData{
+		Database:         "app",
+		User:             "o'neil",
+		ServerVersionNum: 160002,
+		Vars:             map[string]string{"ROLE": "reader"},
+	}
+
+	source := `{{ if ge .ServerVersionNum 150000 }}GRANT ALL ON SCHEMA public TO {{ ident .User }};{{ end }}
+ALTER DATABASE {{ ident .Database }} OWNER TO {{ ident .Vars.ROLE }};
+COMMENT ON DATABASE {{ ident .Database }} IS {{ literal .User }};`
+
+	sql, err := renderTemplate("grants.sql.tmpl", source, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `GRANT ALL ON SCHEMA public TO "o'neil";
+ALTER DATABASE "app" OWNER TO "reader";
+COMMENT ON DATABASE "app" IS 'o''neil';`
+	if sql != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", sql, expected)
+	}
+
+	if _, err := renderTemplate("missing.sql.tmpl", "{{ .Vars.MISSING }}", data); err == nil {
+		t.Errorf("Expected an error for a missing variable")
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	for value, expected := range map[string]string{
+		"abc":   `'abc'`,
+		"it's":  `'it''s'`,
+		`a\b'c`: `E'a\\b''c'`,
+		"":      `''`,
+	} {
+		if got := QuoteLiteral(value); got != expected {
+			t.Errorf("QuoteLiteral(%q) = %s, expected %s", value, got, expected)
+		}
+	}
+}
+
+func TestTargetDatabase(t *testing.T) {
+	ctx := context.Background()
+	if name := targetDatabase(ctx, "postgres"); name != "postgres" {
+		t.Errorf("expected the connected database without target, got %s", name)
+	}
+	if name := targetDatabase(withTargetDatabase(ctx, "app"), "postgres"); name != "app" {
+		t.Errorf("expected the target database, got %s", name)
+	}
+
+	data := &TemplateData{Database: "app", ConnectedDatabase: "postgres"}
+	sql, err := renderTemplate("create.sql.tmpl", `CREATE DATABASE {{ ident .Database }}; -- on {{ .ConnectedDatabase }}`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `CREATE DATABASE "app"; -- on postgres`; sql != expected {
+		t.Errorf("got %s, expected %s", sql, expected)
+	}
+}
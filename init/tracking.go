@@ -0,0 +1,160 @@
+package psqlinit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Default name of the table in which the Runner tracks the applied init
+// scripts.
+const DefaultTrackingTable = "psql_manager_init"
+
+// ChangedScriptMode determines what the Runner does with tracked init
+// scripts whose content changed since they were applied.
+type ChangedScriptMode int8
+
+const (
+	// Apply the changed script again, unless its conditions match.
+	RERUN_CHANGED ChangedScriptMode = iota
+	// Fail with a ChangedScriptError.
+	FAIL_ON_CHANGED
+)
+
+// ChangedScriptError is returned in FAIL_ON_CHANGED mode if a tracked init
+// script changed since it was applied.
+type ChangedScriptError struct {
+	Name string
+}
+
+func (e *ChangedScriptError) Error() string {
+	return fmt.Sprintf("InitScript '%s' changed since it was applied", e.Name)
+}
+
+// ChecksummedInitScript is an InitScript of which the Runner can detect
+// changes by its checksum. Init scripts without a checksum are tracked by
+// name only.
+type ChecksummedInitScript interface {
+	InitScript
+	Checksum() (string, error)
+}
+
+func checksum(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+func (v *initSql) Checksum() (string, error) {
+	return checksum([]byte(v.sql)), nil
+}
+
+func (v *initSqlFile) Checksum() (string, error) {
+	contents, err := fs.ReadFile(v.fs, v.filename)
+	if err != nil {
+		return "", err
+	}
+	return checksum(contents), nil
+}
+
+// ScriptChecksum returns the checksum of the init script, or an empty string
+// if it has none.
+func ScriptChecksum(script InitScript) (string, error) {
+	if s, ok := script.(ChecksummedInitScript); ok {
+		return s.Checksum()
+	}
+	return "", nil
+}
+
+// trackedStatus returns the checksum of the script, and whether it was
+// applied before with the same checksum or changed since it was applied.
+// Returns a ChangedScriptError in FAIL_ON_CHANGED mode if the script changed,
+// unless plan is set.
+func (r *Runner) trackedStatus(script InitScript, tracked map[string]string, plan bool) (checksum string, unchanged bool, changed bool, err error) {
+	checksum, err = ScriptChecksum(script)
+	if err != nil {
+		return "", false, false, fmt.Errorf("InitScript '%s': checksum: %v", script.Name(), err)
+	}
+
+	applied, ok := tracked[script.Name()]
+	if !ok {
+		return checksum, false, false, nil
+	} else if applied == checksum {
+		return checksum, true, false, nil
+	}
+
+	if r.OnChanged == FAIL_ON_CHANGED && !plan {
+		return checksum, false, true, &ChangedScriptError{Name: script.Name()}
+	}
+	return checksum, false, true, nil
+}
+
+func (r *Runner) trackingTable() string {
+	return pgx.Identifier(strings.Split(r.TrackingTable, ".")).Sanitize()
+}
+
+// readTracked creates the tracking table if it does not exist yet, and
+// returns the checksums of the applied init scripts by name.
+func (r *Runner) readTracked(ctx context.Context, conn *pgx.Conn) (map[string]string, error) {
+	if err := r.createTrackingTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	return r.queryTracked(ctx, conn)
+}
+
+// readTrackedIfExists returns the checksums of the applied init scripts by
+// name, without creating the tracking table.
+func (r *Runner) readTrackedIfExists(ctx context.Context, conn *pgx.Conn) (map[string]string, error) {
+	var exists bool
+	err := conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", r.trackingTable()).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to check init tracking table \"%s\": %w", r.TrackingTable, err)
+	}
+	if !exists {
+		return map[string]string{}, nil
+	}
+	return r.queryTracked(ctx, conn)
+}
+
+func (r *Runner) createTrackingTable(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+r.trackingTable()+` (
+  name text PRIMARY KEY,
+  checksum text NOT NULL,
+  applied_at timestamp NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return fmt.Errorf("Failed to create init tracking table \"%s\": %w", r.TrackingTable, err)
+	}
+	return nil
+}
+
+func (r *Runner) queryTracked(ctx context.Context, conn *pgx.Conn) (map[string]string, error) {
+	rows, err := conn.Query(ctx, "SELECT name, checksum FROM "+r.trackingTable())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read init tracking table \"%s\": %w", r.TrackingTable, err)
+	}
+	defer rows.Close()
+
+	res := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, err
+		}
+		res[name] = checksum
+	}
+	return res, rows.Err()
+}
+
+func (r *Runner) recordApplied(ctx context.Context, conn *pgx.Conn, name string, checksum string) error {
+	_, err := conn.Exec(ctx, "INSERT INTO "+r.trackingTable()+` (name, checksum) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = now()`, name, checksum)
+	if err != nil {
+		return fmt.Errorf("Failed to track InitScript '%s': %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package psqlinit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+func TestTrackedStatus(t *testing.T) {
+	script := InitSql("tables", "CREATE TABLE a (id int);")
+	sum, err := ScriptChecksum(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Runner{}
+
+	checksum, unchanged, changed, err := r.trackedStatus(script, map[string]string{}, false)
+	if err != nil || checksum != sum || unchanged || changed {
+		t.Errorf("untracked: got %q, %t, %t, %v", checksum, unchanged, changed, err)
+	}
+
+	_, unchanged, changed, err = r.trackedStatus(script, map[string]string{"tables": sum}, false)
+	if err != nil || !unchanged || changed {
+		t.Errorf("same checksum: expected unchanged, got %t, %t, %v", unchanged, changed, err)
+	}
+
+	_, unchanged, changed, err = r.trackedStatus(script, map[string]string{"tables": "old"}, false)
+	if err != nil || unchanged || !changed {
+		t.Errorf("rerun changed: expected changed, got %t, %t, %v", unchanged, changed, err)
+	}
+}
+
+func TestTrackedStatusFailOnChanged(t *testing.T) {
+	script := InitSql("tables", "CREATE TABLE a (id int);")
+	r := &Runner{OnChanged: FAIL_ON_CHANGED}
+
+	_, _, _, err := r.trackedStatus(script, map[string]string{"tables": "old"}, false)
+	var changedErr *ChangedScriptError
+	if !errors.As(err, &changedErr) || changedErr.Name != "tables" {
+		t.Errorf("expected a ChangedScriptError, got %v", err)
+	}
+
+	_, _, changed, err := r.trackedStatus(script, map[string]string{"tables": "old"}, true)
+	if err != nil || !changed {
+		t.Errorf("expected a plan to report the change without failing, got %t, %v", changed, err)
+	}
+}
+
+func TestTrackedStatusWithoutChecksum(t *testing.T) {
+	script := InitFn("seed", func(ctx context.Context, conn *pgx.Conn) error { return nil })
+	r := &Runner{OnChanged: FAIL_ON_CHANGED}
+
+	checksum, unchanged, changed, err := r.trackedStatus(script, map[string]string{"seed": ""}, false)
+	if err != nil || checksum != "" || !unchanged || changed {
+		t.Errorf("expected scripts without checksum to be tracked by name, got %q, %t, %t, %v", checksum, unchanged, changed, err)
+	}
+}
+
+func TestScriptChecksum(t *testing.T) {
+	fsys := fstest.MapFS{"tables.sql": {Data: []byte("CREATE TABLE a (id int);")}}
+
+	fromFile, err := ScriptChecksum(InitSqlFile(fsys, "tables.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromSql, err := ScriptChecksum(InitSql("tables", "CREATE TABLE a (id int);"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromFile != fromSql || len(fromFile) != 64 {
+		t.Errorf("expected equal sha256 checksums for equal contents, got %q and %q", fromFile, fromSql)
+	}
+
+	if _, err := ScriptChecksum(InitSqlFile(fsys, "missing.sql")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+// statusHandler collects the status of each logged script.
+type statusHandler struct {
+	statuses map[string]string
+}
+
+func (h *statusHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *statusHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *statusHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *statusHandler) Handle(ctx context.Context, rec slog.Record) error {
+	var script, status string
+	rec.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "script":
+			script = a.Value.String()
+		case db.StatusKey:
+			status = a.Value.String()
+		}
+		return true
+	})
+	if len(script) > 0 {
+		h.statuses[script] = status
+	}
+	return nil
+}
+
+func TestRunStepsTracking(t *testing.T) {
+	checksum := func(script InitScript) string {
+		sum, err := ScriptChecksum(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	matches := CustomCond("matches", func(ctx context.Context, conn *pgx.Conn) (bool, error) {
+		return true, nil
+	})
+
+	applied := InitSql("applied", "CREATE TABLE a (id int);")
+	changed := InitSql("changed", "CREATE TABLE b (id int, name text);")
+	added := InitSql("added", "CREATE TABLE c (id int);")
+	after := InitSql("after", "CREATE TABLE d (id int);")
+
+	steps := []initStep{
+		{script: applied},
+		{script: changed, skipWhen: []Condition{matches}},
+		{script: added},
+		{script: after},
+	}
+	tracked := map[string]string{
+		"applied": checksum(applied),
+		"changed": "old",
+		"after":   checksum(after),
+	}
+
+	h := &statusHandler{statuses: map[string]string{}}
+	ctx := db.WithLogger(context.Background(), slog.New(h))
+
+	r := &Runner{}
+	if err := r.runSteps(ctx, nil, nil, steps, tracked, true); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"applied": "SKIPPED",
+		// The conditions of changed scripts are still honoured.
+		"changed": "SKIPPED",
+		"added":   "PENDING",
+		// Tracked scripts stay skipped after a script that is applied.
+		"after": "SKIPPED",
+	}
+	for name, status := range expected {
+		if h.statuses[name] != status {
+			t.Errorf("%s: expected %s, got %q", name, status, h.statuses[name])
+		}
+	}
+}
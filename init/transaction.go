@@ -0,0 +1,96 @@
+package psqlinit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TransactionMode determines how the init scripts on the target database
+// are wrapped in transactions. Cluster init scripts and init scripts wrapped
+// with OnRoot always run without a transaction, as statements like
+// `ALTER SYSTEM`, `CREATE DATABASE` and `CREATE TABLESPACE` can not run in a
+// transaction block.
+type TransactionMode int8
+
+const (
+	// Apply each init script on the target database in its own
+	// transaction, together with its tracking record.
+	TRANSACTION_PER_STEP TransactionMode = iota
+	// Apply all init scripts on the target database in a single
+	// transaction.
+	SINGLE_TRANSACTION
+	// Apply the init scripts without transactions.
+	NO_TRANSACTION
+)
+
+// checkSingleTransaction returns an error if one of the steps on the target
+// database can not run in a transaction.
+func checkSingleTransaction(steps []initStep) error {
+	for _, step := range steps {
+		if IsNoTransaction(step.script) && !IsOnRoot(step.script) {
+			return fmt.Errorf("InitScript '%s' can not run in a transaction, so the init scripts can not run in a single transaction", step.script.Name())
+		}
+	}
+	return nil
+}
+
+// runInTx runs fn in a single transaction on the connection. Rolls back all
+// changes if fn fails.
+func (r *Runner) runInTx(ctx context.Context, conn *pgx.Conn, fn func(conn *pgx.Conn) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to begin init transaction: %w", err)
+	}
+
+	r.tx = tx
+	defer func() { r.tx = nil }()
+
+	if err := fn(tx.Conn()); err != nil {
+		return errors.Join(
+			fmt.Errorf("Rolled back all init scripts: %w", err),
+			tx.Rollback(context.WithoutCancel(ctx)),
+		)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// stepTransaction returns true if the script runs in its own transaction.
+// Scripts on the root database, scripts wrapped with NoTransaction and
+// scripts on the connection of the transaction of the runner do not.
+func (r *Runner) stepTransaction(conn *pgx.Conn, script InitScript, onRoot bool) bool {
+	inRunnerTx := r.tx != nil && r.tx.Conn() == conn
+	return !inRunnerTx && !onRoot && r.TransactionMode != NO_TRANSACTION && !IsNoTransaction(script)
+}
+
+// applyStep applies the script and records it as applied with record. Runs
+// both in a transaction if stepTransaction returns true.
+func (r *Runner) applyStep(ctx context.Context, conn *pgx.Conn, script InitScript, onRoot bool, record func(conn *pgx.Conn) error) (time.Duration, error) {
+	if !r.stepTransaction(conn, script, onRoot) {
+		dur, err := r.runScript(ctx, conn, script)
+		if err != nil {
+			return dur, err
+		}
+		return dur, record(conn)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	dur, err := r.runScript(ctx, tx.Conn(), script)
+	if err != nil {
+		return dur, err
+	}
+	if err := record(tx.Conn()); err != nil {
+		return dur, err
+	}
+
+	return dur, tx.Commit(ctx)
+}
@@ -0,0 +1,69 @@
+package psqlinit
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type connTx struct {
+	pgx.Tx
+	conn *pgx.Conn
+}
+
+func (tx *connTx) Conn() *pgx.Conn { return tx.conn }
+
+func TestStepTransaction(t *testing.T) {
+	conn, rootConn := &pgx.Conn{}, &pgx.Conn{}
+	script := InitSql("a", "SELECT 1;")
+
+	cases := []struct {
+		name     string
+		mode     TransactionMode
+		conn     *pgx.Conn
+		script   InitScript
+		onRoot   bool
+		expected bool
+	}{
+		{"per step", TRANSACTION_PER_STEP, conn, script, false, true},
+		{"no transaction mode", NO_TRANSACTION, conn, script, false, false},
+		{"no transaction script", TRANSACTION_PER_STEP, conn, NoTransaction(script), false, false},
+		{"on root", TRANSACTION_PER_STEP, rootConn, OnRoot(script), true, false},
+		{"cluster", TRANSACTION_PER_STEP, rootConn, script, true, false},
+	}
+	for _, c := range cases {
+		r := &Runner{TransactionMode: c.mode}
+		if res := r.stepTransaction(c.conn, c.script, c.onRoot); res != c.expected {
+			t.Errorf("%s: stepTransaction = %t, expected %t", c.name, res, c.expected)
+		}
+	}
+}
+
+func TestStepTransactionInRunnerTransaction(t *testing.T) {
+	conn, rootConn := &pgx.Conn{}, &pgx.Conn{}
+	script := InitSql("a", "SELECT 1;")
+
+	r := &Runner{TransactionMode: SINGLE_TRANSACTION, tx: &connTx{conn: conn}}
+	if r.stepTransaction(conn, script, false) {
+		t.Errorf("expected no step transaction inside the transaction of the runner")
+	}
+	if r.stepTransaction(rootConn, OnRoot(script), true) {
+		t.Errorf("expected no transaction for a step on the root database")
+	}
+
+	r.tx = &connTx{conn: rootConn}
+	if !r.stepTransaction(conn, script, false) {
+		t.Errorf("expected a step transaction on another connection than the transaction of the runner")
+	}
+}
+
+func TestCheckSingleTransaction(t *testing.T) {
+	script := InitSql("a", "SELECT 1;")
+
+	if err := checkSingleTransaction([]initStep{{script: script}, {script: OnRoot(NoTransaction(script))}}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := checkSingleTransaction([]initStep{{script: script}, {script: NoTransaction(script)}}); err == nil {
+		t.Errorf("expected an error for a NoTransaction script on the target database")
+	}
+}
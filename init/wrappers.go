@@ -0,0 +1,86 @@
+package psqlinit
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+// WrappedInitScript changes how the Runner applies another InitScript.
+// Wrappers can be nested.
+type WrappedInitScript interface {
+	InitScript
+	Unwrap() InitScript
+}
+
+type wrappedScript struct {
+	InitScript
+}
+
+func (w *wrappedScript) Unwrap() InitScript {
+	return w.InitScript
+}
+
+func (w *wrappedScript) ApplyExpanded(ctx context.Context, conn *pgx.Conn, vars *db.Vars) error {
+	if s, ok := w.InitScript.(ExpandingInitScript); ok {
+		return s.ApplyExpanded(ctx, conn, vars)
+	}
+	return w.InitScript.Apply(ctx, conn)
+}
+
+func (w *wrappedScript) Checksum() (string, error) {
+	return ScriptChecksum(w.InitScript)
+}
+
+// findWrapper returns the first wrapper of type T in the wrapper chain of
+// the script.
+func findWrapper[T InitScript](script InitScript) (T, bool) {
+	for script != nil {
+		if w, ok := script.(T); ok {
+			return w, true
+		}
+		wrapped, ok := script.(WrappedInitScript)
+		if !ok {
+			break
+		}
+		script = wrapped.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+type onRootScript struct {
+	wrappedScript
+}
+
+// OnRoot wraps the script such that it is applied on the root database
+// instead of the target database, without a transaction. Its skip
+// conditions are also evaluated on the root database.
+func OnRoot(script InitScript) InitScript {
+	return &onRootScript{wrappedScript{script}}
+}
+
+// IsOnRoot returns true if the script is applied on the root database.
+func IsOnRoot(script InitScript) bool {
+	_, ok := findWrapper[*onRootScript](script)
+	return ok
+}
+
+type noTransactionScript struct {
+	wrappedScript
+}
+
+// NoTransaction wraps the script such that it is not applied in a
+// transaction, like scripts with `CREATE DATABASE` or `CREATE INDEX
+// CONCURRENTLY` statements.
+func NoTransaction(script InitScript) InitScript {
+	return &noTransactionScript{wrappedScript{script}}
+}
+
+// IsNoTransaction returns true if the script is not applied in a transaction.
+func IsNoTransaction(script InitScript) bool {
+	_, ok := findWrapper[*noTransactionScript](script)
+	return ok
+}
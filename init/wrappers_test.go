@@ -0,0 +1,77 @@
+package psqlinit
+
+import "testing"
+
+func TestFindWrapper(t *testing.T) {
+	script := InitSql("a", "SELECT 1;")
+	wrapped := OnRoot(NoTransaction(After(script, "b")))
+
+	onRoot, ok := findWrapper[*onRootScript](wrapped)
+	if !ok || onRoot != wrapped {
+		t.Errorf("expected the outer wrapper to be found")
+	}
+	if _, ok := findWrapper[*noTransactionScript](wrapped); !ok {
+		t.Errorf("expected a nested wrapper to be found")
+	}
+	if s, ok := findWrapper[*initSql](wrapped); !ok || s != script {
+		t.Errorf("expected the wrapped script to be found")
+	}
+	if _, ok := findWrapper[*onRootScript](script); ok {
+		t.Errorf("expected no wrapper on an unwrapped script")
+	}
+	if _, ok := findWrapper[*onRootScript](nil); ok {
+		t.Errorf("expected no wrapper on a nil script")
+	}
+}
+
+func TestIsOnRoot(t *testing.T) {
+	script := InitSql("a", "SELECT 1;")
+
+	cases := []struct {
+		script   InitScript
+		expected bool
+	}{
+		{script, false},
+		{OnRoot(script), true},
+		{NoTransaction(OnRoot(script)), true},
+		{After(OnRoot(script), "b"), true},
+		{NoTransaction(script), false},
+	}
+	for i, c := range cases {
+		if res := IsOnRoot(c.script); res != c.expected {
+			t.Errorf("case %d: IsOnRoot = %t, expected %t", i, res, c.expected)
+		}
+		if c.script.Name() != "a" {
+			t.Errorf("case %d: expected the name of the wrapped script, got %s", i, c.script.Name())
+		}
+	}
+
+	if !IsNoTransaction(NoTransaction(OnRoot(script))) || IsNoTransaction(OnRoot(script)) {
+		t.Errorf("expected IsNoTransaction to find only NoTransaction wrappers")
+	}
+}
+
+func TestAddCluster(t *testing.T) {
+	saved := globalClusterRepository
+	t.Cleanup(func() { globalClusterRepository = saved })
+	globalClusterRepository = Repository{}
+
+	AddCluster(InitSql("roles", "CREATE ROLE app;"))
+	AddClusterSql("settings", "ALTER SYSTEM SET work_mem = '64MB';")
+
+	var r *Runner
+	if r.Cluster() != &globalClusterRepository {
+		t.Errorf("expected a nil runner to use the global cluster repository")
+	}
+	if n := (&Runner{}).Cluster().Len(); n != 2 {
+		t.Errorf("expected 2 scripts in the global cluster repository, got %d", n)
+	}
+
+	own := &Repository{}
+	if (&Runner{ClusterRepository: own}).Cluster() != own {
+		t.Errorf("expected the cluster repository of the runner")
+	}
+	if globalRepository.Len() > 0 {
+		t.Errorf("expected AddCluster not to add to the global repository")
+	}
+}
@@ -1,8 +1,16 @@
 package psqlmanager
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
 )
 
 type LogColorMode int8
@@ -50,3 +58,218 @@ const (
 	LOG_DEFAULT LogVerbosity = 0
 	LOG_VERBOSE LogVerbosity = 1
 )
+
+// level returns the minimum level that is logged with the verbosity.
+func (v LogVerbosity) level() slog.Level {
+	switch {
+	case v < LOG_DEFAULT:
+		return slog.LevelWarn
+	case v > LOG_DEFAULT:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiGray   = "\033[0;90m"
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+)
+
+// ConsoleHandler is a slog.Handler that writes the log messages as plain
+// lines for a terminal. The attributes of a record are only written in
+// verbose mode, as the messages already contain the relevant details.
+//
+// The presentation attributes of a record (see db.PhaseKey) are rendered as
+// part of the line: phase headers are prefixed with ">>", nested records are
+// indented and the status and duration of steps are written in columns. Only
+// the attributes of the record itself are rendered this way, not the
+// attributes added with With.
+type ConsoleHandler struct {
+	w       io.Writer
+	mu      *sync.Mutex
+	level   slog.Level
+	color   bool
+	verbose bool
+	attrs   string
+	group   string
+}
+
+// NewConsoleHandler creates a ConsoleHandler that writes to w. With
+// COLOR_AUTO, colors are used if w is a terminal and NO_COLOR is not set.
+func NewConsoleHandler(w io.Writer, color LogColorMode, verbosity LogVerbosity) *ConsoleHandler {
+	return &ConsoleHandler{
+		w:       w,
+		mu:      &sync.Mutex{},
+		level:   verbosity.level(),
+		color:   useColor(w, color),
+		verbose: verbosity > LOG_DEFAULT,
+	}
+}
+
+func useColor(w io.Writer, mode LogColorMode) bool {
+	if mode != COLOR_AUTO {
+		return mode == COLOR_ALWAYS
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (h *ConsoleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *ConsoleHandler) Handle(ctx context.Context, r slog.Record) error {
+	var phase bool
+	var indent int64
+	var status string
+	var duration *time.Duration
+	r.Attrs(func(a slog.Attr) bool {
+		a.Value = a.Value.Resolve()
+		switch {
+		case a.Key == db.PhaseKey:
+			phase = true
+		case a.Key == db.IndentKey && a.Value.Kind() == slog.KindInt64:
+			indent = a.Value.Int64()
+		case a.Key == db.StatusKey && a.Value.Kind() == slog.KindString:
+			status = a.Value.String()
+		case a.Key == db.DurationKey && a.Value.Kind() == slog.KindDuration:
+			d := a.Value.Duration()
+			duration = &d
+		}
+		return true
+	})
+
+	var b strings.Builder
+	for range indent {
+		b.WriteString("    ")
+	}
+
+	var color string
+	switch {
+	case r.Level >= slog.LevelError:
+		color = ansiRed
+		b.WriteString("ERROR! ")
+	case r.Level >= slog.LevelWarn:
+		color = ansiYellow
+		b.WriteString("WARNING! ")
+	case r.Level < slog.LevelInfo:
+		color = ansiGray
+	case phase:
+		color = ansiBold
+	}
+
+	if phase {
+		b.WriteString(">> ")
+	}
+	if len(status) > 0 {
+		fmt.Fprintf(&b, "%-7s ", status)
+	}
+	if duration != nil {
+		fmt.Fprintf(&b, "%-40s (%6.2fms)", r.Message, duration.Seconds()*1000)
+	} else {
+		b.WriteString(r.Message)
+	}
+
+	if h.verbose {
+		b.WriteString(h.attrs)
+		r.Attrs(func(a slog.Attr) bool {
+			switch a.Key {
+			case db.PhaseKey, db.IndentKey, db.StatusKey, db.DurationKey:
+			default:
+				writeAttr(&b, h.group, a)
+			}
+			return true
+		})
+	}
+
+	line := b.String()
+	if h.color && len(color) > 0 {
+		line = color + line + ansiReset
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.w, line)
+	return err
+}
+
+func writeAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if len(a.Key) > 0 {
+			group += a.Key + "."
+		}
+		for _, attr := range a.Value.Group() {
+			writeAttr(b, group, attr)
+		}
+		return
+	}
+
+	fmt.Fprintf(b, " %s%s=%s", group, a.Key, a.Value)
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		writeAttr(&b, h.group, a)
+	}
+
+	res := *h
+	res.attrs += b.String()
+	return &res
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+
+	res := *h
+	res.group += name + "."
+	return &res
+}
+
+var defaultLogger = slog.New(NewConsoleHandler(os.Stdout, COLOR_AUTO, LOG_DEFAULT))
+
+// logger returns the logger of the config, or a ConsoleHandler logger on
+// stdout if it has none.
+func (c *Config) logger() *slog.Logger {
+	if c == nil {
+		c = &GlobalConfig
+	}
+	if c.Logger == nil {
+		return defaultLogger
+	}
+	return c.Logger
+}
+
+// logContext returns a copy of the context that carries the logger of the
+// config, such that the runners of the phases log through it.
+func (c *Config) logContext(ctx context.Context) context.Context {
+	return db.WithLogger(ctx, c.logger())
+}
+
+// warnDropFailed logs that a temporary database could not be dropped.
+func (c *Config) warnDropFailed(database string, err error) {
+	c.logger().Warn(
+		fmt.Sprintf("Failed to drop database \"%s\". You need to clean up by hand! %v", database, err),
+		"database", database,
+		"error", err,
+	)
+}
@@ -0,0 +1,99 @@
+package psqlmanager
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(NewConsoleHandler(&b, COLOR_NEVER, LOG_DEFAULT))
+
+	logger.Info("INITIALIZE DATABASE", db.Phase("init"), "database", "app")
+	logger.Debug("SELECT 1")
+	logger.Warn("Lock timeout")
+
+	expected := ">> INITIALIZE DATABASE\nWARNING! Lock timeout\n"
+	if b.String() != expected {
+		t.Errorf("got %q, expected %q", b.String(), expected)
+	}
+}
+
+func TestConsoleHandlerColumns(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(NewConsoleHandler(&b, COLOR_NEVER, LOG_DEFAULT))
+
+	logger.Info("schemas.sql", db.Indent(1), db.StatusKey, "OK", db.DurationKey, 1500*time.Microsecond)
+	logger.Info("tables.sql", db.Indent(1), db.StatusKey, "SKIPPED")
+	logger.Info("✓ Schema \"auth\" exists", db.Indent(2))
+	logger.Warn("permission denied", db.Indent(3))
+
+	expected := "    OK      schemas.sql                              (  1.50ms)\n" +
+		"    SKIPPED tables.sql\n" +
+		"        ✓ Schema \"auth\" exists\n" +
+		"            WARNING! permission denied\n"
+	if b.String() != expected {
+		t.Errorf("got %q, expected %q", b.String(), expected)
+	}
+}
+
+func TestConsoleHandlerVerbose(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(NewConsoleHandler(&b, COLOR_NEVER, LOG_VERBOSE)).
+		With("database", "app").
+		WithGroup("init")
+
+	logger.Debug("schemas.sql", db.Indent(1), db.StatusKey, "OK", "script", "schemas.sql")
+
+	expected := "    OK      schemas.sql database=app init.script=schemas.sql\n"
+	if b.String() != expected {
+		t.Errorf("got %q, expected %q", b.String(), expected)
+	}
+}
+
+func TestConsoleHandlerQuiet(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(NewConsoleHandler(&b, COLOR_ALWAYS, LOG_QUIET))
+
+	logger.Info("RUN SEEDERS", db.Phase("seed"))
+	logger.Error("Seeder failed")
+
+	expected := ansiRed + "ERROR! Seeder failed" + ansiReset + "\n"
+	if b.String() != expected {
+		t.Errorf("got %q, expected %q", b.String(), expected)
+	}
+}
+
+func TestConsoleHandlerPhaseColor(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(NewConsoleHandler(&b, COLOR_ALWAYS, LOG_DEFAULT))
+
+	logger.Info(">> not a header")
+	logger.Info("MIGRATION SET \"core\"", db.Phase("migrate"))
+
+	expected := ">> not a header\n" + ansiBold + ">> MIGRATION SET \"core\"" + ansiReset + "\n"
+	if b.String() != expected {
+		t.Errorf("got %q, expected %q", b.String(), expected)
+	}
+}
+
+func TestConfigLoggerFallback(t *testing.T) {
+	config := &Config{}
+	if config.logger() != defaultLogger {
+		t.Errorf("expected the config to fall back to the default console logger")
+	}
+	if _, ok := defaultLogger.Handler().(*ConsoleHandler); !ok {
+		t.Errorf("expected the default logger to use a ConsoleHandler")
+	}
+
+	logger := slog.New(NewConsoleHandler(&bytes.Buffer{}, COLOR_NEVER, LOG_DEFAULT))
+	config.Logger = logger
+	if db.Logger(config.logContext(context.Background())) != logger {
+		t.Errorf("expected the context to carry the logger of the config")
+	}
+}
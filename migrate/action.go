@@ -101,19 +101,19 @@ func (m *migrateTo) RunUsing(ctx context.Context, runner *Runner) ([]*goose.Migr
 			return nil, fmt.Errorf("Could not migrate up. Current version %d is newer than target version %d", version, m.Version)
 		}
 
-		return runner.UpTo(ctx, version)
+		return runner.UpTo(ctx, m.Version)
 
 	case ALLOW_DOWN:
 		if version < m.Version {
 			return nil, fmt.Errorf("Could not migrate down. Current version %d is older than target version %d", version, m.Version)
 		}
-		return runner.DownTo(ctx, version)
+		return runner.DownTo(ctx, m.Version)
 
 	case ALLOW_BOTH:
 		if version < m.Version {
-			return runner.DownTo(ctx, version)
+			return runner.UpTo(ctx, m.Version)
 		} else {
-			return runner.UpTo(ctx, version)
+			return runner.DownTo(ctx, m.Version)
 		}
 
 	default:
@@ -184,6 +184,10 @@ func (upToLatest) String() string {
 	return fmt.Sprintf("Migrate up to latest version")
 }
 
+func (upToLatest) SetOrder() SetOrder {
+	return DECLARED_ORDER
+}
+
 func (upToLatest) RunUsing(ctx context.Context, runner *Runner) ([]*goose.MigrationResult, error) {
 	res, err := runner.Up(ctx)
 	return res, err
@@ -197,6 +201,28 @@ func (reset) String() string {
 	return fmt.Sprintf("Rollback all migrations")
 }
 
+func (reset) SetOrder() SetOrder {
+	return REVERSE_ORDER
+}
+
 func (reset) RunUsing(ctx context.Context, runner *Runner) ([]*goose.MigrationResult, error) {
 	return runner.Reset(ctx)
 }
+
+type baseline struct {
+	Version int64
+}
+
+// BaselineAction marks all migrations up to and including version as
+// applied, without running them.
+func BaselineAction(version int64) MigrateAction {
+	return &baseline{version}
+}
+
+func (m *baseline) String() string {
+	return fmt.Sprintf("Mark migrations up to version %d as applied", m.Version)
+}
+
+func (m *baseline) RunUsing(ctx context.Context, runner *Runner) ([]*goose.MigrationResult, error) {
+	return runner.MarkApplied(ctx, m.Version)
+}
@@ -0,0 +1,188 @@
+package psqlmigrate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+)
+
+type atomic struct {
+	MigrateAction
+}
+
+// AtomicAction wraps the action such that all of its migrations run in a
+// single transaction. If any migration fails, none of them are applied.
+//
+// Refuses to run if the action has to apply Go migrations or migrations
+// marked with `-- +goose NO TRANSACTION`.
+func AtomicAction(action MigrateAction) MigrateAction {
+	if _, ok := action.(*atomic); ok {
+		return action
+	}
+	return &atomic{action}
+}
+
+func (a *atomic) String() string {
+	return a.MigrateAction.String() + " atomically"
+}
+
+func (a *atomic) SetOrder() SetOrder {
+	return ActionSetOrder(a.MigrateAction)
+}
+
+func (a *atomic) RunUsing(ctx context.Context, runner *Runner) ([]*goose.MigrationResult, error) {
+	return runner.runAtomic(ctx, a.MigrateAction)
+}
+
+// runAtomic runs the action in a single transaction, and rolls back all of
+// its migrations if it fails.
+func (r *Runner) runAtomic(ctx context.Context, action MigrateAction) ([]*goose.MigrationResult, error) {
+	if r.tx != nil {
+		return action.RunUsing(ctx, r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.tx = tx
+	defer func() { r.tx = nil }()
+
+	results, err := action.RunUsing(ctx, r)
+	if err != nil {
+		// None of the migrations that ran before the failure are applied.
+		var partial *goose.PartialError
+		if errors.As(err, &partial) {
+			err = &goose.PartialError{Failed: partial.Failed, Err: fmt.Errorf("Rolled back all migrations: %w", partial.Err)}
+		} else {
+			err = fmt.Errorf("Rolled back all migrations: %w", err)
+		}
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Failed to commit migrations: %w", err)
+	}
+	return results, nil
+}
+
+// conn returns the transaction of the atomic action if it runs, or the
+// database otherwise.
+func (r *Runner) conn() database.DBTxConn {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// checkAtomic returns an error if any of the migrations cannot run inside
+// the transaction of an atomic action.
+func (r *Runner) checkAtomic(plan []*goose.Source) error {
+	for _, source := range plan {
+		if source.Type != goose.TypeSQL {
+			return fmt.Errorf("Cannot migrate atomically: %s is a Go migration", source.Path)
+		}
+
+		content, err := fs.ReadFile(r.execFsys, source.Path)
+		if err != nil {
+			return fmt.Errorf("Failed to read migration \"%s\": %w", source.Path, err)
+		}
+		if noTransaction(string(content)) {
+			return fmt.Errorf("Cannot migrate atomically: %s is marked NO TRANSACTION", source.Path)
+		}
+	}
+	return nil
+}
+
+// execAtomic applies the migration in the transaction of the atomic action.
+// The migration runs in a savepoint, such that it can be retried after it
+// failed without aborting the transaction.
+func (r *Runner) execAtomic(ctx context.Context, source *goose.Source, up bool) (*goose.MigrationResult, error) {
+	start := time.Now()
+
+	content, err := fs.ReadFile(r.execFsys, source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := migrationSection(string(content), up)
+	empty := len(strings.TrimSpace(sql)) == 0
+	if !empty {
+		if _, err := r.tx.ExecContext(ctx, "SAVEPOINT psqlmigrate_migration"); err != nil {
+			return nil, err
+		}
+		if _, err := r.tx.ExecContext(ctx, sql); err != nil {
+			_, rollbackErr := r.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT psqlmigrate_migration")
+			return nil, errors.Join(err, rollbackErr)
+		}
+		if _, err := r.tx.ExecContext(ctx, "RELEASE SAVEPOINT psqlmigrate_migration"); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &goose.MigrationResult{Source: source, Direction: "up", Empty: empty}
+	if up {
+		err = r.Store.Insert(ctx, r.tx, database.InsertRequest{Version: source.Version})
+	} else {
+		result.Direction = "down"
+		err = r.Store.Delete(ctx, r.tx, source.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update version table: %w", err)
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// noTransaction returns true if the goose SQL migration is annotated with
+// `-- +goose NO TRANSACTION`.
+func noTransaction(content string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "-- +goose ")
+		if ok && strings.TrimSpace(annotation) == "NO TRANSACTION" {
+			return true
+		}
+	}
+	return false
+}
+
+// migrationSection returns the SQL in the up or down section of a goose SQL
+// migration. The statements are run as a single multi-statement query, so
+// the statement annotations are not needed.
+func migrationSection(content string, up bool) string {
+	want := "Down"
+	if up {
+		want = "Up"
+	}
+
+	var b strings.Builder
+	var in bool
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose "); ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up", "Down":
+				in = strings.TrimSpace(annotation) == want
+			}
+			continue
+		}
+		if in {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+
+	return b.String()
+}
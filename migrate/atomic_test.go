@@ -0,0 +1,104 @@
+package psqlmigrate
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestMigrationSection(t *testing.T) {
+	content := `-- +goose Up
+CREATE TABLE a (id int);
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION f();
+DROP TABLE a;
+`
+
+	up := migrationSection(content, true)
+	expectedUp := "CREATE TABLE a (id int);\nCREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\n\n"
+	if up != expectedUp {
+		t.Errorf("Up section: got %q, expected %q", up, expectedUp)
+	}
+
+	down := migrationSection(content, false)
+	expectedDown := "DROP FUNCTION f();\nDROP TABLE a;\n"
+	if down != expectedDown {
+		t.Errorf("Down section: got %q, expected %q", down, expectedDown)
+	}
+}
+
+func TestNoTransaction(t *testing.T) {
+	cases := map[string]bool{
+		"-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\n": true,
+		"  -- +goose NO TRANSACTION  \n-- +goose Up\n":                                         true,
+		"-- +goose Up\nCREATE TABLE a (id int);\n":                                             false,
+		"-- Do not add +goose NO TRANSACTION here.\n-- +goose Up\n":                            false,
+		"-- +goose Up\nSELECT '-- +goose NO TRANSACTION x';\n":                                 false,
+	}
+	for content, expected := range cases {
+		if res := noTransaction(content); res != expected {
+			t.Errorf("noTransaction(%q) = %t, expected %t", content, res, expected)
+		}
+	}
+}
+
+func TestCheckAtomic(t *testing.T) {
+	r := &Runner{execFsys: fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\n-- Unlike +goose NO TRANSACTION migrations.\nCREATE TABLE a (id int);\n")},
+		"00002_b.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\n")},
+	}}
+	source := func(typ goose.MigrationType, path string) *goose.Source {
+		return &goose.Source{Type: typ, Path: path}
+	}
+
+	if err := r.checkAtomic([]*goose.Source{source(goose.TypeSQL, "00001_a.sql")}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := r.checkAtomic([]*goose.Source{source(goose.TypeSQL, "00001_a.sql"), source(goose.TypeSQL, "00002_b.sql")}); err == nil {
+		t.Errorf("expected an error for a NO TRANSACTION migration")
+	}
+	if err := r.checkAtomic([]*goose.Source{source(goose.TypeGo, "00003_c.go")}); err == nil {
+		t.Errorf("expected an error for a Go migration")
+	}
+}
+
+type partialAction struct{ err error }
+
+func (a *partialAction) String() string { return "partial" }
+
+func (a *partialAction) RunUsing(ctx context.Context, runner *Runner) ([]*goose.MigrationResult, error) {
+	return nil, a.err
+}
+
+func TestRunAtomicRollsBack(t *testing.T) {
+	r := txRunner(t)
+
+	failed := &goose.MigrationResult{Source: &goose.Source{Version: 2}}
+	_, err := r.runAtomic(context.Background(), &partialAction{&goose.PartialError{
+		Applied: []*goose.MigrationResult{{Source: &goose.Source{Version: 1}}},
+		Failed:  failed,
+		Err:     errors.New("failure"),
+	}})
+
+	var partial *goose.PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected a PartialError, got %v", err)
+	}
+	if len(partial.Applied) > 0 || partial.Failed != failed {
+		t.Errorf("expected no applied migrations after the rollback, got %v", partial.Applied)
+	}
+	if applied := appliedResults(nil, err); len(applied) > 0 {
+		t.Errorf("expected no checksums to be recorded, got %v", applied)
+	}
+	if !slices.Equal(txEvents.events, []string{"begin", "rollback"}) {
+		t.Errorf("expected the transaction to be rolled back, got %v", txEvents.events)
+	}
+}
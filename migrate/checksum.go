@@ -0,0 +1,230 @@
+package psqlmigrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+)
+
+// ModifiedMigrationsError is returned in strict mode if applied migrations
+// have changed since they were applied.
+type ModifiedMigrationsError struct {
+	Versions []int64
+}
+
+func (e *ModifiedMigrationsError) Error() string {
+	versions := make([]string, len(e.Versions))
+	for i, v := range e.Versions {
+		versions[i] = fmt.Sprintf("%05d", v)
+	}
+	return fmt.Sprintf("Applied migrations were modified since they were applied: %s", strings.Join(versions, ", "))
+}
+
+// ChecksumTableName returns the name of the table that holds the checksums
+// of the migrations applied in the version table.
+func ChecksumTableName(versionTable string) string {
+	return versionTable + "_checksums"
+}
+
+// ChecksumTable returns the name of the table that holds the checksums of
+// the applied migrations. It lives next to the goose version table.
+func (r *Runner) ChecksumTable() string {
+	return ChecksumTableName(r.Store.Tablename())
+}
+
+const baselineSuffix = "_baseline.sql"
+
+// BaselineFileName returns the file name of the baseline migration that
+// squashes all migrations up to and including the version.
+func BaselineFileName(version int64) string {
+	return fmt.Sprintf("%05d%s", version, baselineSuffix)
+}
+
+// IsBaselineMigration reports whether the migration file is a baseline
+// migration written by squashing migrations.
+func IsBaselineMigration(path string) bool {
+	return strings.HasSuffix(path, baselineSuffix)
+}
+
+// MigrationChecksum returns the hex encoded sha256 hash of the contents of
+// the migration file. Returns an empty string for Go migrations and for
+// baseline migrations, as a baseline replaces migrations that were already
+// applied under the same version with different contents.
+func MigrationChecksum(fsys fs.FS, source *goose.Source) (string, error) {
+	if fsys == nil || source.Type != goose.TypeSQL || len(source.Path) == 0 || IsBaselineMigration(source.Path) {
+		return "", nil
+	}
+
+	content, err := fs.ReadFile(fsys, source.Path)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func (r *Runner) ensureChecksumTable(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+qualifiedIdentifier(r.ChecksumTable())+` (
+  version_id bigint PRIMARY KEY,
+  checksum text NOT NULL,
+  recorded_at timestamp NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return fmt.Errorf("Failed to create checksum table \"%s\": %w", r.ChecksumTable(), err)
+	}
+	return nil
+}
+
+// ReadChecksums reads the recorded checksums of the applied migrations by
+// version. Returns an empty map if no checksums were recorded yet.
+func (r *Runner) ReadChecksums(ctx context.Context) (map[int64]string, error) {
+	res := make(map[int64]string)
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", qualifiedIdentifier(r.ChecksumTable())).Scan(&exists)
+	if err != nil || !exists {
+		return res, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT version_id, checksum FROM "+qualifiedIdentifier(r.ChecksumTable()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		res[version] = checksum
+	}
+
+	return res, rows.Err()
+}
+
+// RecordChecksums records the checksums of the migrations that were applied
+// and removes the checksums of the migrations that were rolled back.
+func (r *Runner) RecordChecksums(ctx context.Context, results []*goose.MigrationResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	if err := r.ensureChecksumTable(ctx); err != nil {
+		return err
+	}
+
+	table := qualifiedIdentifier(r.ChecksumTable())
+	for _, result := range results {
+		if result == nil || result.Error != nil {
+			continue
+		}
+
+		version := result.Source.Version
+		if result.Direction == "down" {
+			_, err := r.db.ExecContext(ctx, "DELETE FROM "+table+" WHERE version_id = $1", version)
+			if err != nil {
+				return fmt.Errorf("Failed to remove checksum of migration %d: %w", version, err)
+			}
+			continue
+		}
+
+		checksum, err := MigrationChecksum(r.fsys, result.Source)
+		if err != nil {
+			return fmt.Errorf("Failed to compute checksum of migration %d: %w", version, err)
+		}
+		if len(checksum) == 0 {
+			continue
+		}
+
+		_, err = r.db.ExecContext(ctx, "INSERT INTO "+table+` (version_id, checksum) VALUES ($1, $2)
+ON CONFLICT (version_id) DO UPDATE SET checksum = EXCLUDED.checksum, recorded_at = now()`, version, checksum)
+		if err != nil {
+			return fmt.Errorf("Failed to record checksum of migration %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// ModifiedVersions returns the versions of the applied migrations whose
+// file content differs from the content at the time they were applied.
+// Migrations that were applied without recording a checksum and baseline
+// migrations are ignored.
+func (r *Runner) ModifiedVersions(ctx context.Context, statuses []*goose.MigrationStatus) ([]int64, error) {
+	checksums, err := r.ReadChecksums(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read migration checksums: %w", err)
+	}
+
+	return modifiedVersions(r.fsys, statuses, checksums)
+}
+
+func modifiedVersions(fsys fs.FS, statuses []*goose.MigrationStatus, checksums map[int64]string) ([]int64, error) {
+	var res []int64
+	for _, s := range statuses {
+		recorded, ok := checksums[s.Source.Version]
+		if s.State != goose.StateApplied || !ok {
+			continue
+		}
+
+		checksum, err := MigrationChecksum(fsys, s.Source)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to compute checksum of migration %d: %w", s.Source.Version, err)
+		}
+		if len(checksum) > 0 && checksum != recorded {
+			res = append(res, s.Source.Version)
+		}
+	}
+
+	return res, nil
+}
+
+// checkModified returns a ModifiedMigrationsError if any applied migrations
+// were modified.
+func (r *Runner) checkModified(ctx context.Context) error {
+	statuses, err := r.Provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	modified, err := r.ModifiedVersions(ctx, statuses)
+	if err != nil {
+		return err
+	}
+	if len(modified) > 0 {
+		return &ModifiedMigrationsError{Versions: modified}
+	}
+	return nil
+}
+
+// appliedResults returns the results of all migrations that ran, including
+// the migrations that ran before a migration failed.
+func appliedResults(results []*goose.MigrationResult, err error) []*goose.MigrationResult {
+	res := slices.Clone(results)
+
+	var walk func(err error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case *goose.PartialError:
+			res = append(res, e.Applied...)
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+
+	return res
+}
@@ -0,0 +1,91 @@
+package psqlmigrate
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestMigrationChecksum(t *testing.T) {
+	fsys := fstest.MapFS{
+		"00001_a.sql":        {Data: []byte("SELECT 1;")},
+		"00002_b.sql":        {Data: []byte("SELECT 1;")},
+		"00003_c.sql":        {Data: []byte("SELECT 2;")},
+		"00003_baseline.sql": {Data: []byte("SELECT 3;")},
+	}
+	checksum := func(typ goose.MigrationType, path string) string {
+		res, err := MigrationChecksum(fsys, &goose.Source{Type: typ, Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	a, b, c := checksum(goose.TypeSQL, "00001_a.sql"), checksum(goose.TypeSQL, "00002_b.sql"), checksum(goose.TypeSQL, "00003_c.sql")
+	if len(a) != 64 {
+		t.Errorf("expected a hex encoded sha256 hash, got %q", a)
+	}
+	if a != b {
+		t.Errorf("expected equal checksums for equal contents, got %s and %s", a, b)
+	}
+	if a == c {
+		t.Errorf("expected different checksums for different contents")
+	}
+
+	if res := checksum(goose.TypeGo, "00004_d.go"); res != "" {
+		t.Errorf("expected no checksum for Go migrations, got %s", res)
+	}
+	if res := checksum(goose.TypeSQL, "00003_baseline.sql"); res != "" {
+		t.Errorf("expected no checksum for baseline migrations, got %s", res)
+	}
+
+	if _, err := MigrationChecksum(fsys, &goose.Source{Type: goose.TypeSQL, Path: "00005_missing.sql"}); err == nil {
+		t.Errorf("expected an error for a missing migration file")
+	}
+}
+
+func TestModifiedVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"00001_a.sql":        {Data: []byte("SELECT 1;")},
+		"00002_b.sql":        {Data: []byte("SELECT 2;")},
+		"00003_baseline.sql": {Data: []byte("SELECT 3;")},
+		"00004_d.sql":        {Data: []byte("SELECT 4;")},
+		"00005_e.sql":        {Data: []byte("SELECT 5;")},
+	}
+	status := func(version int64, path string, state goose.State) *goose.MigrationStatus {
+		return &goose.MigrationStatus{
+			Source: &goose.Source{Type: goose.TypeSQL, Path: path, Version: version},
+			State:  state,
+		}
+	}
+	checksum := func(path string) string {
+		res, err := MigrationChecksum(fsys, &goose.Source{Type: goose.TypeSQL, Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	modified, err := modifiedVersions(fsys, []*goose.MigrationStatus{
+		status(1, "00001_a.sql", goose.StateApplied),
+		status(2, "00002_b.sql", goose.StateApplied),
+		status(3, "00003_baseline.sql", goose.StateApplied),
+		status(4, "00004_d.sql", goose.StateApplied),
+		status(5, "00005_e.sql", goose.StatePending),
+	}, map[int64]string{
+		1: checksum("00001_a.sql"),
+		2: "changed",
+		3: "superseded",
+		5: "changed",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 is a baseline, 4 has no recorded checksum and 5 is not applied.
+	if !slices.Equal(modified, []int64{2}) {
+		t.Errorf("expected modified versions [2], got %v", modified)
+	}
+}
@@ -0,0 +1,306 @@
+package psqlmigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/pressly/goose/v3"
+)
+
+// MigrationHookContext is passed to the hooks that run before and after
+// each individual migration.
+type MigrationHookContext struct {
+	Action    MigrateAction
+	Source    *goose.Source
+	Direction string
+
+	// Transaction in which the hooks run. It is committed if all hooks
+	// succeed, and rolled back otherwise.
+	//
+	// This is NOT the transaction of the migration itself: the before hooks
+	// are committed before the migration starts, and the after hooks run
+	// after the migration was committed. Only atomic actions run the hooks
+	// and the migrations in the same transaction.
+	Tx *sql.Tx
+
+	// Result of the migration. Nil in the before hooks.
+	Result *goose.MigrationResult
+}
+
+// ActionHookContext is passed to the hooks that run before and after a
+// complete migrate action.
+type ActionHookContext struct {
+	Action MigrateAction
+
+	// Transaction in which the hooks run. It is committed if all hooks
+	// succeed, and rolled back otherwise. It is separate from the
+	// transactions of the migrations, unless the action is atomic.
+	Tx *sql.Tx
+
+	// Results and error of the action. Empty in the before hooks.
+	Results []*goose.MigrationResult
+	Err     error
+}
+
+// A MigrationHook aborts the migrate action if it returns an error.
+type MigrationHook func(ctx context.Context, hc *MigrationHookContext) error
+
+// An ActionHook aborts the migrate action if it returns an error.
+type ActionHook func(ctx context.Context, hc *ActionHookContext) error
+
+// Hooks are run by the Runner around migrate actions and around each
+// individual migration. If migration hooks are set, the Runner applies the
+// migrations one by one.
+//
+// The hooks of each stage run in order in their own transaction. The first
+// hook that fails stops the remaining hooks of the stage, rolls back the
+// stage and aborts the action.
+type Hooks struct {
+	BeforeAction    []ActionHook
+	AfterAction     []ActionHook
+	BeforeMigration []MigrationHook
+	AfterMigration  []MigrationHook
+}
+
+func (h *Hooks) Copy() Hooks {
+	return Hooks{
+		BeforeAction:    slices.Clone(h.BeforeAction),
+		AfterAction:     slices.Clone(h.AfterAction),
+		BeforeMigration: slices.Clone(h.BeforeMigration),
+		AfterMigration:  slices.Clone(h.AfterMigration),
+	}
+}
+
+func (h *Hooks) hasMigrationHooks() bool {
+	return len(h.BeforeMigration) > 0 || len(h.AfterMigration) > 0
+}
+
+// inTx runs fn in a new transaction, or in the transaction of the atomic
+// action if it runs.
+func (r *Runner) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+func (r *Runner) runActionHooks(ctx context.Context, hooks []ActionHook, hc ActionHookContext) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		hc.Tx = tx
+		for _, hook := range hooks {
+			if err := hook(ctx, &hc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Runner) runMigrationHooks(ctx context.Context, hooks []MigrationHook, hc MigrationHookContext) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		hc.Tx = tx
+		for _, hook := range hooks {
+			if err := hook(ctx, &hc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// stepPlan returns the sources of the migrations that have to run to
+// migrate in the direction up to (and including) or down to (excluding) the
+// version, in the order in which they have to run.
+func (r *Runner) stepPlan(ctx context.Context, up bool, version int64) ([]*goose.Source, error) {
+	statuses, err := r.Provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[int64]*goose.Source, len(statuses))
+	for _, s := range statuses {
+		sources[s.Source.Version] = s.Source
+	}
+
+	var res []*goose.Source
+	if up {
+		if !r.AllowMissing {
+			if err := checkMissing(statuses); err != nil {
+				return nil, err
+			}
+		}
+		applied, err := r.appliedInTx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range statuses {
+			pending := s.State == goose.StatePending
+			if applied != nil {
+				pending = !applied[s.Source.Version]
+			}
+			if pending && s.Source.Version <= version {
+				res = append(res, s.Source)
+			}
+		}
+		return res, nil
+	}
+
+	// Roll back in the reverse order in which the migrations were applied.
+	applied, err := r.Store.ListMigrations(ctx, r.conn())
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range applied {
+		if !m.IsApplied || m.Version <= version || m.Version == 0 {
+			continue
+		}
+		source, ok := sources[m.Version]
+		if !ok {
+			return nil, fmt.Errorf("Cannot roll back migration %d: migration file not found", m.Version)
+		}
+		res = append(res, source)
+	}
+	return res, nil
+}
+
+// appliedInTx returns the applied versions as seen by the transaction of the
+// atomic action. Returns nil if no atomic action runs.
+func (r *Runner) appliedInTx(ctx context.Context) (map[int64]bool, error) {
+	if r.tx == nil {
+		return nil, nil
+	}
+
+	migrations, err := r.Store.ListMigrations(ctx, r.tx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[int64]bool, len(migrations))
+	for _, m := range migrations {
+		res[m.Version] = m.IsApplied
+	}
+	return res, nil
+}
+
+// stepwise returns true if the migrations have to be applied one by one,
+// to run the migration hooks or to retry individual migrations. Atomic
+// actions always apply the migrations one by one.
+func (r *Runner) stepwise() bool {
+	return r.Hooks.hasMigrationHooks() || r.Retry.enabled()
+}
+
+// runSteps applies the migrations in the direction one by one, and runs the
+// migration hooks around each of them. In an atomic action, the migrations
+// and the hooks run in the transaction of the action.
+func (r *Runner) runSteps(ctx context.Context, up bool, version int64) ([]*goose.MigrationResult, error) {
+	plan, err := r.stepPlan(ctx, up, version)
+	if err != nil {
+		return nil, err
+	}
+	if r.tx != nil {
+		if err := r.checkAtomic(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	var results []*goose.MigrationResult
+	for _, source := range plan {
+		hc := MigrationHookContext{
+			Action:    r.action,
+			Source:    source,
+			Direction: direction,
+		}
+
+		if err := r.runMigrationHooks(ctx, r.Hooks.BeforeMigration, hc); err != nil {
+			return nil, &goose.PartialError{
+				Applied: results,
+				Failed:  &goose.MigrationResult{Source: source, Direction: direction, Error: err},
+				Err:     fmt.Errorf("Before migration hook: %w", err),
+			}
+		}
+
+		result, err := r.applyVersion(ctx, source, up)
+		if err != nil {
+			var partial *goose.PartialError
+			if errors.As(err, &partial) {
+				return nil, &goose.PartialError{Applied: results, Failed: partial.Failed, Err: partial.Err}
+			}
+			return nil, &goose.PartialError{
+				Applied: results,
+				Failed:  &goose.MigrationResult{Source: source, Direction: direction, Error: err},
+				Err:     err,
+			}
+		}
+		results = append(results, result)
+
+		hc.Result = result
+		if err := r.runMigrationHooks(ctx, r.Hooks.AfterMigration, hc); err != nil {
+			return nil, &goose.PartialError{
+				Applied: results,
+				Failed:  &goose.MigrationResult{Source: source, Direction: direction, Error: err},
+				Err:     fmt.Errorf("After migration hook: %w", err),
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Up migrates to the latest version. Runs the migrations one by one if
+// migration hooks or retries are set, or if the action is atomic.
+func (r *Runner) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	if r.tx == nil && !r.stepwise() {
+		if err := r.checkMissing(ctx); err != nil {
+			return nil, err
+		}
+		return r.Provider.Up(ctx)
+	}
+	return r.runSteps(ctx, true, goose.MaxVersion)
+}
+
+// UpTo migrates up to the version. Runs the migrations one by one if
+// migration hooks or retries are set, or if the action is atomic.
+func (r *Runner) UpTo(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	if r.tx == nil && !r.stepwise() {
+		if err := r.checkMissing(ctx); err != nil {
+			return nil, err
+		}
+		return r.Provider.UpTo(ctx, version)
+	}
+	return r.runSteps(ctx, true, version)
+}
+
+// DownTo rolls back to the version. Runs the migrations one by one if
+// migration hooks or retries are set, or if the action is atomic.
+func (r *Runner) DownTo(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	if r.tx == nil && !r.stepwise() {
+		return r.Provider.DownTo(ctx, version)
+	}
+	return r.runSteps(ctx, false, version)
+}
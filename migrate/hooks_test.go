@@ -0,0 +1,169 @@
+package psqlmigrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// txDriver is a database/sql driver that only supports transactions, and
+// records when they are committed or rolled back.
+type txDriver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (d *txDriver) record(event string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, event)
+}
+
+func (d *txDriver) Open(name string) (driver.Conn, error) { return &txConn{d}, nil }
+
+type txConn struct{ d *txDriver }
+
+func (c *txConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *txConn) Close() error { return nil }
+func (c *txConn) Begin() (driver.Tx, error) {
+	c.d.record("begin")
+	return c, nil
+}
+func (c *txConn) Commit() error {
+	c.d.record("commit")
+	return nil
+}
+func (c *txConn) Rollback() error {
+	c.d.record("rollback")
+	return nil
+}
+
+var txEvents = &txDriver{}
+
+func init() {
+	sql.Register("psqlmigrate_hooks_test", txEvents)
+}
+
+func txRunner(t *testing.T) *Runner {
+	db, err := sql.Open("psqlmigrate_hooks_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	txEvents.mu.Lock()
+	txEvents.events = nil
+	txEvents.mu.Unlock()
+
+	return &Runner{db: db}
+}
+
+func TestMigrationHooksOrder(t *testing.T) {
+	r := txRunner(t)
+
+	var calls []string
+	hook := func(name string) MigrationHook {
+		return func(ctx context.Context, hc *MigrationHookContext) error {
+			if hc.Tx == nil {
+				t.Errorf("hook %s: expected a transaction", name)
+			}
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	err := r.runMigrationHooks(context.Background(), []MigrationHook{hook("a"), hook("b"), hook("c")}, MigrationHookContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(calls, []string{"a", "b", "c"}) {
+		t.Errorf("expected hooks a, b, c in order, got %v", calls)
+	}
+	if !slices.Equal(txEvents.events, []string{"begin", "commit"}) {
+		t.Errorf("expected all hooks in a single committed transaction, got %v", txEvents.events)
+	}
+}
+
+func TestActionHooksError(t *testing.T) {
+	r := txRunner(t)
+
+	failure := errors.New("failure")
+	var calls []string
+	hooks := []ActionHook{
+		func(ctx context.Context, hc *ActionHookContext) error {
+			calls = append(calls, "a")
+			return nil
+		},
+		func(ctx context.Context, hc *ActionHookContext) error {
+			calls = append(calls, "b")
+			return failure
+		},
+		func(ctx context.Context, hc *ActionHookContext) error {
+			calls = append(calls, "c")
+			return nil
+		},
+	}
+
+	err := r.runActionHooks(context.Background(), hooks, ActionHookContext{})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the hook error, got %v", err)
+	}
+
+	if !slices.Equal(calls, []string{"a", "b"}) {
+		t.Errorf("expected the hooks after the failing hook to be skipped, got %v", calls)
+	}
+	if !slices.Equal(txEvents.events, []string{"begin", "rollback"}) {
+		t.Errorf("expected the transaction to be rolled back, got %v", txEvents.events)
+	}
+}
+
+func TestHooksInAtomicTransaction(t *testing.T) {
+	r := txRunner(t)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.tx = tx
+
+	var hookTx *sql.Tx
+	err = r.runMigrationHooks(context.Background(), []MigrationHook{
+		func(ctx context.Context, hc *MigrationHookContext) error {
+			hookTx = hc.Tx
+			return errors.New("failure")
+		},
+	}, MigrationHookContext{})
+	if err == nil {
+		t.Fatal("expected the hook error")
+	}
+
+	if hookTx != tx {
+		t.Errorf("expected the hooks to run in the transaction of the atomic action")
+	}
+	// The atomic action rolls back its own transaction.
+	if !slices.Equal(txEvents.events, []string{"begin"}) {
+		t.Errorf("expected the hooks to leave the atomic transaction open, got %v", txEvents.events)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNoHooks(t *testing.T) {
+	r := txRunner(t)
+
+	if err := r.runActionHooks(context.Background(), nil, ActionHookContext{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(txEvents.events) > 0 {
+		t.Errorf("expected no transaction without hooks, got %v", txEvents.events)
+	}
+}
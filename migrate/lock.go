@@ -1 +1,241 @@
 package psqlmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pressly/goose/v3/lock"
+	psqldb "github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+// DefaultLockId is the advisory lock id that is used to serialize
+// migrations. It is the same id that goose uses, such that psql-manager
+// also serializes with the plain goose cli.
+const DefaultLockId int64 = lock.DefaultLockID
+
+// AdvisoryLock is a session-level advisory lock on the target database that
+// is held while a migrate action runs.
+type AdvisoryLock struct {
+	Id int64
+
+	// How long to wait for the lock if another session holds it. Fails
+	// immediately if zero.
+	WaitTimeout time.Duration
+
+	// How often to retry while waiting for the lock.
+	PollInterval time.Duration
+}
+
+func DefaultAdvisoryLock() *AdvisoryLock {
+	return &AdvisoryLock{
+		Id:           DefaultLockId,
+		WaitTimeout:  5 * time.Minute,
+		PollInterval: time.Second,
+	}
+}
+
+func (l *AdvisoryLock) Copy() *AdvisoryLock {
+	if l == nil {
+		return nil
+	}
+	res := *l
+	return &res
+}
+
+type LockHolder struct {
+	Pid             uint32
+	ApplicationName string
+	ClientAddr      string
+}
+
+func (h *LockHolder) String() string {
+	res := fmt.Sprintf("backend pid %d", h.Pid)
+	var details []string
+	if len(h.ApplicationName) > 0 {
+		details = append(details, fmt.Sprintf("application \"%s\"", h.ApplicationName))
+	}
+	if len(h.ClientAddr) > 0 {
+		details = append(details, "client "+h.ClientAddr)
+	}
+	if len(details) > 0 {
+		res += " (" + strings.Join(details, ", ") + ")"
+	}
+	return res
+}
+
+type LockHeldError struct {
+	Id      int64
+	Holders []LockHolder
+	Waited  time.Duration
+}
+
+func (e *LockHeldError) Error() string {
+	holders := make([]string, len(e.Holders))
+	for i, h := range e.Holders {
+		holders[i] = h.String()
+	}
+
+	holder := "another session"
+	if len(holders) > 0 {
+		holder = strings.Join(holders, ", ")
+	}
+
+	return fmt.Sprintf("Migration lock %d is held by %s (waited %s)", e.Id, holder, e.Waited)
+}
+
+const lockHoldersQuery = `
+SELECT l.pid, COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), '')
+FROM pg_catalog.pg_locks l
+LEFT JOIN pg_catalog.pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory'
+  AND l.granted
+  AND l.objsubid = 1
+  AND l.database = (SELECT oid FROM pg_catalog.pg_database WHERE datname = current_database())
+  AND ((l.classid::bigint << 32) | l.objid::bigint) = $1
+ORDER BY l.pid
+`
+
+type lockSession struct {
+	tryLock func(ctx context.Context) (bool, error)
+	unlock  func(ctx context.Context) (bool, error)
+	holders func(ctx context.Context) ([]LockHolder, error)
+}
+
+func (l *AdvisoryLock) acquire(ctx context.Context, s lockSession) error {
+	start := time.Now()
+	var reported []LockHolder
+
+	for {
+		locked, err := s.tryLock(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to acquire migration lock %d: %w", l.Id, err)
+		}
+		if locked {
+			return nil
+		}
+
+		holders, err := s.holders(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to find holder of migration lock %d: %w", l.Id, err)
+		}
+
+		waited := time.Since(start)
+		if waited >= l.WaitTimeout {
+			return &LockHeldError{Id: l.Id, Holders: holders, Waited: waited.Round(time.Millisecond)}
+		}
+
+		if reported == nil && len(holders) > 0 {
+			reported = holders
+			psqldb.Logger(ctx).Info(fmt.Sprintf("WAITING for migration lock %d held by %s", l.Id, &holders[0]),
+				psqldb.Phase("lock"),
+				"lock", l.Id,
+				"holder", holders[0].String(),
+			)
+		}
+
+		interval := l.PollInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		interval = min(interval, l.WaitTimeout-waited)
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (l *AdvisoryLock) release(ctx context.Context, s lockSession) error {
+	unlocked, err := s.unlock(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to release migration lock %d: %w", l.Id, err)
+	}
+	if !unlocked {
+		return fmt.Errorf("Failed to release migration lock %d: lock was not held", l.Id)
+	}
+	return nil
+}
+
+func (l *AdvisoryLock) pgxSession(conn *pgx.Conn) lockSession {
+	return lockSession{
+		tryLock: func(ctx context.Context) (res bool, err error) {
+			err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", l.Id).Scan(&res)
+			return
+		},
+		unlock: func(ctx context.Context) (res bool, err error) {
+			err = conn.QueryRow(ctx, "SELECT pg_advisory_unlock($1)", l.Id).Scan(&res)
+			return
+		},
+		holders: func(ctx context.Context) ([]LockHolder, error) {
+			rows, err := conn.Query(ctx, lockHoldersQuery, l.Id)
+			if err != nil {
+				return nil, err
+			}
+			return pgx.CollectRows(rows, func(row pgx.CollectableRow) (h LockHolder, err error) {
+				err = row.Scan(&h.Pid, &h.ApplicationName, &h.ClientAddr)
+				return
+			})
+		},
+	}
+}
+
+func (l *AdvisoryLock) sqlSession(conn *sql.Conn) lockSession {
+	return lockSession{
+		tryLock: func(ctx context.Context) (res bool, err error) {
+			err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.Id).Scan(&res)
+			return
+		},
+		unlock: func(ctx context.Context) (res bool, err error) {
+			err = conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", l.Id).Scan(&res)
+			return
+		},
+		holders: func(ctx context.Context) ([]LockHolder, error) {
+			rows, err := conn.QueryContext(ctx, lockHoldersQuery, l.Id)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			var res []LockHolder
+			for rows.Next() {
+				var h LockHolder
+				if err := rows.Scan(&h.Pid, &h.ApplicationName, &h.ClientAddr); err != nil {
+					return nil, err
+				}
+				res = append(res, h)
+			}
+			return res, rows.Err()
+		},
+	}
+}
+
+// Acquire takes the lock on the session of conn. Call the returned function
+// to release the lock again.
+func (l *AdvisoryLock) Acquire(ctx context.Context, conn *pgx.Conn) (release func(context.Context) error, err error) {
+	s := l.pgxSession(conn)
+	if err := l.acquire(ctx, s); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		return l.release(ctx, s)
+	}, nil
+}
+
+// SessionLock implements the goose lock.SessionLocker interface.
+func (l *AdvisoryLock) SessionLock(ctx context.Context, conn *sql.Conn) error {
+	return l.acquire(ctx, l.sqlSession(conn))
+}
+
+// SessionUnlock implements the goose lock.SessionLocker interface.
+func (l *AdvisoryLock) SessionUnlock(ctx context.Context, conn *sql.Conn) error {
+	return l.release(ctx, l.sqlSession(conn))
+}
+
+var _ lock.SessionLocker = (*AdvisoryLock)(nil)
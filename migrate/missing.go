@@ -0,0 +1,102 @@
+package psqlmigrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+)
+
+// MissingMigrationsError is returned when migrating up while there are
+// pending migrations with a lower version than the current version of the
+// database, and missing migrations are not allowed.
+type MissingMigrationsError struct {
+	Versions  []int64
+	DBVersion int64
+}
+
+func (e *MissingMigrationsError) Error() string {
+	versions := make([]string, len(e.Versions))
+	for i, v := range e.Versions {
+		versions[i] = fmt.Sprintf("%05d", v)
+	}
+	return fmt.Sprintf("Found %d missing migrations older than current version %d: %s. Allow missing migrations to apply them",
+		len(e.Versions),
+		e.DBVersion,
+		strings.Join(versions, ", "),
+	)
+}
+
+// checkMissing returns a MissingMigrationsError if any of the pending
+// migrations have a version lower than the current database version.
+func checkMissing(statuses []*goose.MigrationStatus) error {
+	var dbVersion int64
+	for _, s := range statuses {
+		if s.State == goose.StateApplied {
+			dbVersion = max(dbVersion, s.Source.Version)
+		}
+	}
+
+	var missing []int64
+	for _, s := range statuses {
+		if s.State == goose.StatePending && s.Source.Version < dbVersion {
+			missing = append(missing, s.Source.Version)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &MissingMigrationsError{Versions: missing, DBVersion: dbVersion}
+	}
+	return nil
+}
+
+func (r *Runner) checkMissing(ctx context.Context) error {
+	if r.AllowMissing {
+		return nil
+	}
+
+	statuses, err := r.Provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return checkMissing(statuses)
+}
+
+// OrphanedVersions returns the versions that are applied according to the
+// version table, but that have no migration source.
+//
+// Versions below the version of a baseline migration are not orphaned, as
+// they were squashed into the baseline.
+func (r *Runner) OrphanedVersions(ctx context.Context) ([]int64, error) {
+	applied, err := r.Store.ListMigrations(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return orphanedVersions(applied, r.Provider.ListSources()), nil
+}
+
+func orphanedVersions(applied []*database.ListMigrationsResult, sources []*goose.Source) []int64 {
+	var baseline int64
+	versions := make(map[int64]bool)
+	for _, s := range sources {
+		versions[s.Version] = true
+		if IsBaselineMigration(s.Path) {
+			baseline = max(baseline, s.Version)
+		}
+	}
+
+	var res []int64
+	for _, m := range applied {
+		if !m.IsApplied || m.Version == 0 || m.Version < baseline || versions[m.Version] || slices.Contains(res, m.Version) {
+			continue
+		}
+		res = append(res, m.Version)
+	}
+
+	slices.Sort(res)
+	return res
+}
@@ -0,0 +1,73 @@
+package psqlmigrate
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+)
+
+func TestCheckMissing(t *testing.T) {
+	status := func(version int64, state goose.State) *goose.MigrationStatus {
+		return &goose.MigrationStatus{
+			Source: &goose.Source{Type: goose.TypeSQL, Version: version},
+			State:  state,
+		}
+	}
+
+	err := checkMissing([]*goose.MigrationStatus{
+		status(1, goose.StateApplied),
+		status(2, goose.StatePending),
+		status(3, goose.StatePending),
+		status(4, goose.StateApplied),
+		status(5, goose.StatePending),
+	})
+
+	var missing *MissingMigrationsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected a MissingMigrationsError, got %v", err)
+	}
+	if missing.DBVersion != 4 || !slices.Equal(missing.Versions, []int64{2, 3}) {
+		t.Errorf("expected missing versions [2 3] below 4, got %v below %d", missing.Versions, missing.DBVersion)
+	}
+
+	err = checkMissing([]*goose.MigrationStatus{
+		status(1, goose.StateApplied),
+		status(2, goose.StateApplied),
+		status(3, goose.StatePending),
+	})
+	if err != nil {
+		t.Errorf("expected no missing migrations, got %v", err)
+	}
+}
+
+func TestOrphanedVersions(t *testing.T) {
+	applied := func(versions ...int64) []*database.ListMigrationsResult {
+		var res []*database.ListMigrationsResult
+		for _, v := range versions {
+			res = append(res, &database.ListMigrationsResult{Version: v, IsApplied: true})
+		}
+		return res
+	}
+	source := func(version int64, path string) *goose.Source {
+		return &goose.Source{Type: goose.TypeSQL, Version: version, Path: path}
+	}
+
+	orphaned := orphanedVersions(
+		append(applied(0, 4, 2, 1, 3), &database.ListMigrationsResult{Version: 5, IsApplied: false}),
+		[]*goose.Source{source(1, "00001_a.sql"), source(3, "00003_c.sql")},
+	)
+	if !slices.Equal(orphaned, []int64{2, 4}) {
+		t.Errorf("expected orphaned versions [2 4], got %v", orphaned)
+	}
+
+	orphaned = orphanedVersions(
+		applied(1, 2, 3, 4, 6),
+		[]*goose.Source{source(3, "00003_baseline.sql"), source(5, "00005_e.sql")},
+	)
+	if !slices.Equal(orphaned, []int64{4, 6}) {
+		t.Errorf("expected the squashed versions not to be orphaned, got %v", orphaned)
+	}
+}
@@ -4,19 +4,62 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"log/slog"
+	"maps"
 	"path/filepath"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+	psqldb "github.com/shared-digitaltechnologies/psql-manager/db"
 )
 
+// ProviderFactory opens goose providers on a database. The factory always
+// configures the goose Store itself, so the ProviderOptions must not contain
+// a goose.WithStore option.
 type ProviderFactory struct {
 	ProviderOptions []goose.ProviderOption
 	MigrationsFsys  fs.FS
+
+	// Name of the goose version table. Uses goose.DefaultTablename if empty.
+	VersionTable string
+
+	// Lock serializes concurrent migrate actions on the same database.
+	// Locking is disabled if nil.
+	Lock *AdvisoryLock
+
+	// Refuse to run migrate actions if applied migrations were modified.
+	StrictChecksums bool
+
+	// Apply pending migrations that are older than the current version,
+	// instead of failing.
+	AllowMissing bool
+
+	// Hooks that run around migrate actions and each migration.
+	Hooks Hooks
+
+	// Substitutes the placeholders in the SQL migrations. No substitution
+	// if nil.
+	Vars *psqldb.Vars
+
+	// Timeouts of the sessions that run the migrations.
+	Timeouts psqldb.SessionTimeouts
+
+	// Retries migrations that failed with a lock timeout.
+	Retry RetryPolicy
+
+	// Run all migrations of an action in a single transaction.
+	Atomic bool
+
+	// Schema in which the migrations are applied. Uses the default
+	// search_path if empty. See ForSchema.
+	Schema string
 }
 
-var globalProviderFactory ProviderFactory
+var globalProviderFactory = ProviderFactory{
+	Lock: DefaultAdvisoryLock(),
+}
 
 func (r *ProviderFactory) Copy() *ProviderFactory {
 	if r == nil {
@@ -28,6 +71,16 @@ func (r *ProviderFactory) Copy() *ProviderFactory {
 	return &ProviderFactory{
 		ProviderOptions: options,
 		MigrationsFsys:  r.MigrationsFsys,
+		VersionTable:    r.VersionTable,
+		Lock:            r.Lock.Copy(),
+		StrictChecksums: r.StrictChecksums,
+		AllowMissing:    r.AllowMissing,
+		Hooks:           r.Hooks.Copy(),
+		Vars:            r.Vars,
+		Timeouts:        r.Timeouts,
+		Retry:           r.Retry,
+		Atomic:          r.Atomic,
+		Schema:          r.Schema,
 	}
 }
 
@@ -74,40 +127,144 @@ func SetMigrationsDir(fsys fs.FS, dirpath ...string) error {
 	return globalProviderFactory.SetMigrationsDir(fsys, dirpath...)
 }
 
+func (r *ProviderFactory) versionTable() string {
+	if len(r.VersionTable) == 0 {
+		return goose.DefaultTablename
+	}
+	return r.VersionTable
+}
+
+func (r *ProviderFactory) SetVersionTable(name string) {
+	if r == nil {
+		r = &globalProviderFactory
+	}
+
+	r.VersionTable = name
+}
+
+func (r *ProviderFactory) GetVersionTable() string {
+	if r == nil {
+		r = &globalProviderFactory
+	}
+
+	return r.versionTable()
+}
+
+func (r *ProviderFactory) SetLock(lock *AdvisoryLock) {
+	if r == nil {
+		r = &globalProviderFactory
+	}
+
+	r.Lock = lock
+}
+
+func (r *ProviderFactory) MigrationLock() *AdvisoryLock {
+	if r == nil {
+		r = &globalProviderFactory
+	}
+
+	return r.Lock
+}
+
+func SetLock(lock *AdvisoryLock) {
+	globalProviderFactory.SetLock(lock)
+}
+
+// OpenProvider opens a goose provider on the database. The provider uses the
+// advisory lock of the factory as its session locker.
 func (r *ProviderFactory) OpenProvider(ctx context.Context, connConfig *pgx.ConnConfig) (provider *goose.Provider, err error) {
 	if r == nil {
 		r = &globalProviderFactory
 	}
 
-	k := len(r.ProviderOptions)
-	options := make([]goose.ProviderOption, k)
-	copy(options, r.ProviderOptions)
+	runner, err := r.open(ctx, connConfig, r.Lock != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return runner.Provider, nil
+}
+
+func (r *ProviderFactory) open(ctx context.Context, connConfig *pgx.ConnConfig, withLock bool) (*Runner, error) {
+	store, err := database.NewStore(database.DialectPostgres, r.versionTable())
+	if err != nil {
+		return nil, fmt.Errorf("Error creating goose Store for MigrationRunner: %v", err)
+	}
+
+	options := make([]goose.ProviderOption, 0, len(r.ProviderOptions)+3)
+	options = append(options, goose.WithStore(store))
+	if r.AllowMissing {
+		options = append(options, goose.WithAllowOutofOrder(true))
+	}
+	if withLock {
+		options = append(options, goose.WithSessionLocker(r.Lock))
+	}
+	options = append(options, r.ProviderOptions...)
+
+	// The advisory lock connection does not get the session timeouts of the
+	// migrations, as it waits for the lock using its own timeout.
+	lockConfig := connConfig.Copy()
+	connConfig = connConfig.Copy()
+	if connConfig.RuntimeParams == nil {
+		connConfig.RuntimeParams = make(map[string]string)
+	}
+	maps.Copy(connConfig.RuntimeParams, r.Timeouts.RuntimeParams())
+	if searchPath := r.searchPath(); len(searchPath) > 0 {
+		connConfig.RuntimeParams["search_path"] = searchPath
+	}
 
 	db := stdlib.OpenDB(*connConfig)
 
-	provider, err = goose.NewProvider(goose.DialectPostgres, db, r.MigrationsFsys, options...)
+	execFsys := psqldb.ExpandFS(r.MigrationsFsys, r.Vars)
+	provider, err := goose.NewProvider("", db, execFsys, options...)
 	if err != nil {
-		return provider, fmt.Errorf("Error creating goose Provider for MigrationRunner: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("Error creating goose Provider for MigrationRunner: %v", err)
 	}
 
-	return provider, nil
+	return &Runner{
+		Provider:   provider,
+		Store:      store,
+		fsys:       r.MigrationsFsys,
+		execFsys:   execFsys,
+		db:         db,
+		lockConfig: lockConfig,
+	}, nil
 }
 
+// OpenRunner opens a migration runner on the database. Unlike the provider,
+// the runner holds the advisory lock for the duration of a complete migrate
+// action.
 func (r *ProviderFactory) OpenRunner(ctx context.Context, connConfig *pgx.ConnConfig) (runner *Runner, err error) {
 	if r == nil {
 		r = &globalProviderFactory
 	}
 
-	provider, err := r.OpenProvider(ctx, connConfig)
+	runner, err = r.open(ctx, connConfig, false)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Runner{provider}, nil
+	runner.Lock = r.Lock.Copy()
+	runner.Strict = r.StrictChecksums
+	runner.AllowMissing = r.AllowMissing
+	runner.Retry = r.Retry
+	runner.Atomic = r.Atomic
+	runner.Hooks = r.Hooks.Copy()
+	return runner, nil
 }
 
+// LogMigrationResults logs the results with slog.Default().
 func LogMigrationResults(results ...*goose.MigrationResult) {
+	LogMigrationResultsTo(slog.Default(), results...)
+}
+
+// LogMigrationResultsTo logs the results with the logger.
+func LogMigrationResultsTo(logger *slog.Logger, results ...*goose.MigrationResult) {
 	for _, res := range results {
-		fmt.Printf("    %s\n", res)
+		if res == nil {
+			continue
+		}
+		logger.Info(res.String(), psqldb.Indent(1))
 	}
 }
@@ -0,0 +1,73 @@
+package psqlmigrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+// RetryPolicy determines how often a migration that failed with a lock
+// timeout is retried. Retries are disabled if Attempts is at most 1.
+type RetryPolicy struct {
+	// Total number of attempts per migration.
+	Attempts int
+
+	// Wait time before the first retry. Doubles after each retry.
+	Backoff time.Duration
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p.Attempts > 1
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	return p.Backoff << (attempt - 1)
+}
+
+// canRetry returns false for migrations that do not run in a transaction,
+// as they may have been applied partially.
+func (r *Runner) canRetry(source *goose.Source) bool {
+	if source.Type != goose.TypeSQL || r.fsys == nil {
+		return false
+	}
+
+	content, err := fs.ReadFile(r.fsys, source.Path)
+	return err == nil && !noTransaction(string(content))
+}
+
+// applyVersion applies the migration, and retries it according to the
+// retry policy if it failed with a lock timeout. Applies the migration in the
+// transaction of the atomic action if it runs.
+func (r *Runner) applyVersion(ctx context.Context, source *goose.Source, up bool) (*goose.MigrationResult, error) {
+	apply := func() (*goose.MigrationResult, error) {
+		if r.tx != nil {
+			return r.execAtomic(ctx, source, up)
+		}
+		return r.Provider.ApplyVersion(ctx, source.Version, up)
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := apply()
+		if err == nil || attempt >= r.Retry.Attempts || !db.IsLockTimeout(err) || !r.canRetry(source) {
+			return result, err
+		}
+
+		wait := r.Retry.backoff(attempt)
+		db.Logger(ctx).Warn(fmt.Sprintf("RETRY migration %s in %s (attempt %d of %d): lock timeout",
+			source.Path, wait, attempt+1, r.Retry.Attempts),
+			"migration", source.Path,
+			"attempt", attempt+1,
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package psqlmigrate
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{Attempts: 4, Backoff: time.Second}
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second} {
+		if d := p.backoff(attempt); d != expected {
+			t.Errorf("backoff(%d) = %s, expected %s", attempt, d, expected)
+		}
+	}
+
+	if (&RetryPolicy{Attempts: 1}).enabled() {
+		t.Errorf("expected retries to be disabled with a single attempt")
+	}
+	if !p.enabled() {
+		t.Errorf("expected retries to be enabled with multiple attempts")
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	r := &Runner{fsys: fstest.MapFS{
+		"00001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id int);\n")},
+		"00002_b.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\n")},
+	}}
+
+	cases := []struct {
+		source   *goose.Source
+		expected bool
+	}{
+		{&goose.Source{Type: goose.TypeSQL, Path: "00001_a.sql"}, true},
+		{&goose.Source{Type: goose.TypeSQL, Path: "00002_b.sql"}, false},
+		{&goose.Source{Type: goose.TypeSQL, Path: "00003_missing.sql"}, false},
+		{&goose.Source{Type: goose.TypeGo, Path: "00004_d.go"}, false},
+	}
+	for _, c := range cases {
+		if res := r.canRetry(c.source); res != c.expected {
+			t.Errorf("canRetry(%s) = %t, expected %t", c.source.Path, res, c.expected)
+		}
+	}
+
+	if (&Runner{}).canRetry(cases[0].source) {
+		t.Errorf("expected no retries without migrations filesystem")
+	}
+}
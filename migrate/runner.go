@@ -2,16 +2,53 @@ package psqlmigrate
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
 )
 
 type Runner struct {
 	*goose.Provider
+
+	// Store manages the goose version table of the provider.
+	Store database.Store
+
+	// Lock is held while running a migrate action. Does not lock if nil.
+	Lock *AdvisoryLock
+
+	// Refuse to run migrate actions if applied migrations were modified
+	// since they were applied.
+	Strict bool
+
+	// Apply pending migrations that are older than the current version.
+	AllowMissing bool
+
+	// Retries migrations that failed with a lock timeout.
+	Retry RetryPolicy
+
+	// Hooks that run around the migrate action and each migration.
+	Hooks Hooks
+
+	// Run all migrations of an action in a single transaction.
+	Atomic bool
+
+	action MigrateAction
+	// Transaction of the running atomic action.
+	tx   *sql.Tx
+	fsys fs.FS
+	// Migrations filesystem with the placeholders substituted.
+	execFsys fs.FS
+	db       *sql.DB
+	// Connection config of the advisory lock, without the session timeouts.
+	lockConfig *pgx.ConnConfig
 }
 
 type MigrateActionResult struct {
@@ -65,9 +102,75 @@ func (r *MigrateActionResult) String() string {
 	return title + "\n" + r.ResultSummary("    ")
 }
 
+func (r *Runner) lock(ctx context.Context) (unlock func() error, err error) {
+	if r.Lock == nil {
+		return func() error { return nil }, nil
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, r.lockConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect for migration lock: %w", err)
+	}
+
+	release, err := r.Lock.Acquire(ctx, conn)
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	return func() error {
+		ctx := context.WithoutCancel(ctx)
+		err := release(ctx)
+		return errors.Join(err, conn.Close(ctx))
+	}, nil
+}
+
+// runLocked runs the action while the runner holds the migration lock.
+func (r *Runner) runLocked(ctx context.Context, action MigrateAction) ([]*goose.MigrationResult, error) {
+	if r.Strict {
+		if err := r.checkModified(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	r.action = action
+	defer func() { r.action = nil }()
+
+	err := r.runActionHooks(ctx, r.Hooks.BeforeAction, ActionHookContext{Action: action})
+	if err != nil {
+		return nil, fmt.Errorf("Before action hook: %w", err)
+	}
+
+	var results []*goose.MigrationResult
+	if r.Atomic {
+		results, err = r.runAtomic(ctx, action)
+	} else {
+		results, err = action.RunUsing(ctx, r)
+	}
+	err = errors.Join(err, r.RecordChecksums(ctx, appliedResults(results, err)))
+
+	hookErr := r.runActionHooks(ctx, r.Hooks.AfterAction, ActionHookContext{
+		Action:  action,
+		Results: results,
+		Err:     err,
+	})
+	if hookErr != nil {
+		err = errors.Join(err, fmt.Errorf("After action hook: %w", hookErr))
+	}
+
+	return results, err
+}
+
 func (r *Runner) Run(ctx context.Context, action MigrateAction) (*MigrateActionResult, error) {
 	start := time.Now()
-	results, err := action.RunUsing(ctx, r)
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return &MigrateActionResult{Action: action, Duration: time.Since(start), Err: err}, err
+	}
+
+	results, err := r.runLocked(ctx, action)
+	err = errors.Join(err, unlock())
 	end := time.Now()
 
 	result := &MigrateActionResult{
@@ -80,6 +183,49 @@ func (r *Runner) Run(ctx context.Context, action MigrateAction) (*MigrateActionR
 	return result, err
 }
 
+// MarkApplied records all pending migrations up to and including version as
+// applied in the version table, without running them.
+func (r *Runner) MarkApplied(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	// Status also creates the version table if it does not exist yet.
+	statuses, err := r.Provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(statuses, func(s *goose.MigrationStatus) bool { return s.Source.Version == version }) {
+		return nil, fmt.Errorf("No migration with version %d", version)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var results []*goose.MigrationResult
+	for _, s := range statuses {
+		if s.Source.Version > version || s.State == goose.StateApplied {
+			continue
+		}
+
+		err := r.Store.Insert(ctx, tx, database.InsertRequest{Version: s.Source.Version})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to mark migration %d as applied: %w", s.Source.Version, err)
+		}
+
+		results = append(results, &goose.MigrationResult{
+			Source:    s.Source,
+			Direction: "up",
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
 func (r *Runner) Close() error {
 	return r.Provider.Close()
 }
@@ -0,0 +1,50 @@
+package psqlmigrate
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ForSchema returns a copy of the factory that applies the migrations in the
+// schema. The sessions use the schema as their search_path, followed by
+// public, and the version table lives in the schema. The advisory lock is
+// derived from the schema, such that schemas in the same database can be
+// migrated concurrently.
+func (r *ProviderFactory) ForSchema(schema string) *ProviderFactory {
+	res := r.Copy()
+	res.Schema = schema
+
+	table := res.versionTable()
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	res.VersionTable = schema + "." + table
+
+	if res.Lock != nil {
+		res.Lock.Id = res.Lock.Id ^ schemaLockKey(schema)
+	}
+
+	return res
+}
+
+func schemaLockKey(schema string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(schema))
+	return int64(h.Sum64())
+}
+
+// searchPath returns the search_path of the sessions, or an empty string to
+// keep the default.
+func (r *ProviderFactory) searchPath() string {
+	if len(r.Schema) == 0 {
+		return ""
+	}
+	return pgx.Identifier{r.Schema}.Sanitize() + ", public"
+}
+
+// qualifiedIdentifier sanitizes a possibly schema-qualified table name.
+func qualifiedIdentifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
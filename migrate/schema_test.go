@@ -0,0 +1,59 @@
+package psqlmigrate
+
+import "testing"
+
+func TestForSchema(t *testing.T) {
+	factory := &ProviderFactory{VersionTable: "public.goose_db_version_core", Lock: DefaultAdvisoryLock()}
+
+	tenant := factory.ForSchema("tenant_a")
+	if tenant.Schema != "tenant_a" {
+		t.Errorf("expected schema tenant_a, got %s", tenant.Schema)
+	}
+	if tenant.VersionTable != "tenant_a.goose_db_version_core" {
+		t.Errorf("expected the version table in the schema, got %s", tenant.VersionTable)
+	}
+	if p := tenant.searchPath(); p != `"tenant_a", public` {
+		t.Errorf("unexpected search_path %s", p)
+	}
+
+	if factory.Schema != "" || factory.VersionTable != "public.goose_db_version_core" || factory.Lock.Id != DefaultLockId {
+		t.Errorf("expected ForSchema not to modify the factory")
+	}
+
+	other := factory.ForSchema("tenant_b")
+	if tenant.Lock.Id == factory.Lock.Id || tenant.Lock.Id == other.Lock.Id {
+		t.Errorf("expected distinct lock ids per schema, got %d, %d and %d", factory.Lock.Id, tenant.Lock.Id, other.Lock.Id)
+	}
+	if again := factory.ForSchema("tenant_a"); again.Lock.Id != tenant.Lock.Id {
+		t.Errorf("expected a stable lock id for the same schema")
+	}
+
+	if (&ProviderFactory{}).ForSchema("tenant_a").VersionTable != "tenant_a.goose_db_version" {
+		t.Errorf("expected the default version table in the schema")
+	}
+	if (&ProviderFactory{}).ForSchema("tenant_a").Lock != nil {
+		t.Errorf("expected no lock if the factory has no lock")
+	}
+}
+
+func TestSchemaLockKey(t *testing.T) {
+	if schemaLockKey("tenant_a") != schemaLockKey("tenant_a") {
+		t.Errorf("expected a deterministic lock key")
+	}
+	if schemaLockKey("tenant_a") == schemaLockKey("tenant_b") {
+		t.Errorf("expected different lock keys for different schemas")
+	}
+}
+
+func TestQualifiedIdentifier(t *testing.T) {
+	cases := map[string]string{
+		"goose_db_version":          `"goose_db_version"`,
+		"tenant_a.goose_db_version": `"tenant_a"."goose_db_version"`,
+		`we"ird.Table`:              `"we""ird"."Table"`,
+	}
+	for name, expected := range cases {
+		if res := qualifiedIdentifier(name); res != expected {
+			t.Errorf("qualifiedIdentifier(%q) = %s, expected %s", name, res, expected)
+		}
+	}
+}
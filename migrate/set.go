@@ -0,0 +1,69 @@
+package psqlmigrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/pressly/goose/v3"
+)
+
+// MigrationSet is a named set of migrations that is tracked in its own
+// goose version table.
+type MigrationSet struct {
+	Name         string
+	Fsys         fs.FS
+	VersionTable string
+}
+
+// NewMigrationSet creates a migration set for the migrations in the
+// directory. The set is tracked in version table "goose_db_version_NAME".
+func NewMigrationSet(name string, fsys fs.FS, dirpath ...string) (MigrationSet, error) {
+	set := MigrationSet{
+		Name:         name,
+		Fsys:         fsys,
+		VersionTable: goose.DefaultTablename + "_" + name,
+	}
+
+	if len(dirpath) > 0 {
+		dir := filepath.Join(dirpath...)
+
+		var err error
+		set.Fsys, err = fs.Sub(fsys, dir)
+		if err != nil {
+			return set, fmt.Errorf("Failed to create subfilesystem for dir '%s': %w", dir, err)
+		}
+	}
+
+	return set, nil
+}
+
+// ForSet returns a copy of the provider factory that opens providers for
+// the migrations of the set.
+func (r *ProviderFactory) ForSet(set *MigrationSet) *ProviderFactory {
+	res := r.Copy()
+	res.MigrationsFsys = set.Fsys
+	res.VersionTable = set.VersionTable
+	return res
+}
+
+type SetOrder int8
+
+const (
+	// The action can only run on a single migration set.
+	SINGLE_SET SetOrder = iota
+	// The action runs on all migration sets in their declared order.
+	DECLARED_ORDER
+	// The action runs on all migration sets in reverse declared order.
+	REVERSE_ORDER
+)
+
+// ActionSetOrder returns how the action is applied when multiple migration
+// sets are configured. Actions that target a specific version or a number of
+// steps are only meaningful within a single set.
+func ActionSetOrder(action MigrateAction) SetOrder {
+	if a, ok := action.(interface{ SetOrder() SetOrder }); ok {
+		return a.SetOrder()
+	}
+	return SINGLE_SET
+}
@@ -0,0 +1,193 @@
+package psqlmigrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+type MigrationState string
+
+const (
+	STATE_APPLIED MigrationState = "applied"
+	STATE_PENDING MigrationState = "pending"
+	// A pending migration that is older than the latest applied migration.
+	STATE_MISSING MigrationState = "missing"
+)
+
+type MigrationStatus struct {
+	Version   int64          `json:"version" yaml:"version"`
+	Path      string         `json:"path" yaml:"path"`
+	Type      string         `json:"type" yaml:"type"`
+	State     MigrationState `json:"state" yaml:"state"`
+	AppliedAt *time.Time     `json:"applied_at" yaml:"applied_at"`
+	// Set if the migration was applied after a migration with a higher version.
+	OutOfOrder bool `json:"out_of_order" yaml:"out_of_order"`
+	// Set if the migration file changed since the migration was applied.
+	Modified bool `json:"modified" yaml:"modified"`
+}
+
+type StatusReport struct {
+	Set           string            `json:"set,omitempty" yaml:"set,omitempty"`
+	Version       int64             `json:"version" yaml:"version"`
+	LatestVersion int64             `json:"latest_version" yaml:"latest_version"`
+	Migrations    []MigrationStatus `json:"migrations" yaml:"migrations"`
+	// Versions in the version table without a migration source.
+	Orphaned []int64 `json:"orphaned" yaml:"orphaned"`
+}
+
+func NewStatusReport(statuses []*goose.MigrationStatus) *StatusReport {
+	res := &StatusReport{
+		Migrations: make([]MigrationStatus, len(statuses)),
+	}
+
+	for i, s := range statuses {
+		m := MigrationStatus{
+			Version: s.Source.Version,
+			Path:    s.Source.Path,
+			Type:    string(s.Source.Type),
+			State:   STATE_PENDING,
+		}
+
+		if s.State == goose.StateApplied {
+			appliedAt := s.AppliedAt
+			m.State = STATE_APPLIED
+			m.AppliedAt = &appliedAt
+			res.Version = max(res.Version, m.Version)
+		}
+
+		res.LatestVersion = max(res.LatestVersion, m.Version)
+		res.Migrations[i] = m
+	}
+
+	// Statuses are ordered by version, so walk back to compare each migration
+	// with the migrations that have a higher version.
+	var firstAppliedAfter *time.Time
+	for i := len(res.Migrations) - 1; i >= 0; i-- {
+		m := &res.Migrations[i]
+		switch m.State {
+		case STATE_PENDING:
+			if m.Version < res.Version {
+				m.State = STATE_MISSING
+			}
+		case STATE_APPLIED:
+			if firstAppliedAfter != nil && m.AppliedAt.After(*firstAppliedAfter) {
+				m.OutOfOrder = true
+			}
+			if firstAppliedAfter == nil || m.AppliedAt.Before(*firstAppliedAfter) {
+				firstAppliedAfter = m.AppliedAt
+			}
+		}
+	}
+
+	return res
+}
+
+func ReadStatusReport(ctx context.Context, provider *goose.Provider) (*StatusReport, error) {
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewStatusReport(statuses), nil
+}
+
+// StatusReport reads the migration status, including which applied
+// migrations were modified since they were applied.
+func (r *Runner) StatusReport(ctx context.Context) (*StatusReport, error) {
+	statuses, err := r.Provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	modified, err := r.ModifiedVersions(ctx, statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	orphaned, err := r.OrphanedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewStatusReport(statuses)
+	for i := range res.Migrations {
+		res.Migrations[i].Modified = slices.Contains(modified, res.Migrations[i].Version)
+	}
+	res.Orphaned = orphaned
+	return res, nil
+}
+
+func (r *StatusReport) Count(state MigrationState) int {
+	count := 0
+	for _, m := range r.Migrations {
+		if m.State == state {
+			count++
+		}
+	}
+	return count
+}
+
+// MissingVersions returns the versions of the pending migrations that are
+// older than the current version.
+func (r *StatusReport) MissingVersions() []int64 {
+	var res []int64
+	for _, m := range r.Migrations {
+		if m.State == STATE_MISSING {
+			res = append(res, m.Version)
+		}
+	}
+	return res
+}
+
+func (r *StatusReport) CountModified() int {
+	count := 0
+	for _, m := range r.Migrations {
+		if m.Modified {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *StatusReport) CountOutOfOrder() int {
+	count := 0
+	for _, m := range r.Migrations {
+		if m.OutOfOrder {
+			count++
+		}
+	}
+	return count
+}
+
+// Check returns an error if the database is not at the latest version, or
+// if any migrations are missing, were applied out of order or were modified.
+func (r *StatusReport) Check() error {
+	var problems []string
+
+	if n := r.Count(STATE_PENDING); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d pending", n))
+	}
+	if n := r.Count(STATE_MISSING); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d missing", n))
+	}
+	if n := r.CountOutOfOrder(); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d out-of-order", n))
+	}
+	if n := r.CountModified(); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d modified", n))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Database at version %d is not up to date with latest version %d: %s migrations",
+			r.Version,
+			r.LatestVersion,
+			strings.Join(problems, ", "),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package psqlmigrate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestNewStatusReport(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	status := func(version int64, appliedAt *time.Time) *goose.MigrationStatus {
+		s := &goose.MigrationStatus{
+			Source: &goose.Source{Type: goose.TypeSQL, Version: version},
+			State:  goose.StatePending,
+		}
+		if appliedAt != nil {
+			s.State = goose.StateApplied
+			s.AppliedAt = *appliedAt
+		}
+		return s
+	}
+	at := func(minutes int) *time.Time {
+		t := t0.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+
+	report := NewStatusReport([]*goose.MigrationStatus{
+		status(1, at(0)),
+		status(2, nil),
+		status(3, at(2)),
+		status(4, at(1)),
+		status(5, nil),
+	})
+
+	if report.Version != 4 || report.LatestVersion != 5 {
+		t.Errorf("expected version 4 of 5, got %d of %d", report.Version, report.LatestVersion)
+	}
+
+	expected := []struct {
+		state      MigrationState
+		outOfOrder bool
+	}{
+		{STATE_APPLIED, false},
+		{STATE_MISSING, false},
+		{STATE_APPLIED, true},
+		{STATE_APPLIED, false},
+		{STATE_PENDING, false},
+	}
+	for i, e := range expected {
+		m := report.Migrations[i]
+		if m.State != e.state || m.OutOfOrder != e.outOfOrder {
+			t.Errorf("migration %d: expected %s (out of order: %v), got %s (out of order: %v)",
+				m.Version, e.state, e.outOfOrder, m.State, m.OutOfOrder)
+		}
+	}
+
+	if report.Check() == nil {
+		t.Errorf("expected check to fail")
+	}
+}
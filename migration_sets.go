@@ -0,0 +1,117 @@
+package psqlmanager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+)
+
+type migrationSetFactory struct {
+	name    string
+	factory *psqlmigrate.ProviderFactory
+}
+
+// MigrationSetNames returns the names of the selected migration sets in
+// their declared order. Returns nil if no migration sets are configured.
+func (c *Config) MigrationSetNames() []string {
+	if c == nil {
+		c = &GlobalConfig
+	}
+
+	var res []string
+	for _, set := range c.migrationSets {
+		if len(c.onlyMigrationSets) == 0 || slices.Contains(c.onlyMigrationSets, set.Name) {
+			res = append(res, set.Name)
+		}
+	}
+	return res
+}
+
+// migrationSetFactories returns the provider factories of the selected
+// migration sets in declared order. If no migration sets are configured,
+// it returns the migration provider factory of the config as the only
+// (unnamed) set.
+func (c *Config) migrationSetFactories() ([]migrationSetFactory, error) {
+	if len(c.migrationSets) == 0 {
+		if len(c.onlyMigrationSets) > 0 {
+			return nil, fmt.Errorf("Migration sets '%s' not found: no migration sets configured", strings.Join(c.onlyMigrationSets, "', '"))
+		}
+		return []migrationSetFactory{{"", c.migrationProviderFactory}}, nil
+	}
+
+	var notFound []string
+	for _, name := range c.onlyMigrationSets {
+		if !slices.ContainsFunc(c.migrationSets, func(set psqlmigrate.MigrationSet) bool { return set.Name == name }) {
+			notFound = append(notFound, name)
+		}
+	}
+	if len(notFound) > 0 {
+		return nil, fmt.Errorf("Migration sets '%s' not found", strings.Join(notFound, "', '"))
+	}
+
+	var res []migrationSetFactory
+	for i := range c.migrationSets {
+		set := &c.migrationSets[i]
+		if len(c.onlyMigrationSets) == 0 || slices.Contains(c.onlyMigrationSets, set.Name) {
+			res = append(res, migrationSetFactory{set.Name, c.migrationProviderFactory.ForSet(set)})
+		}
+	}
+	return res, nil
+}
+
+// migrationSetFactoriesFor returns the provider factories of the selected
+// migration sets in the order in which the action should run on them.
+func (c *Config) migrationSetFactoriesFor(action psqlmigrate.MigrateAction) ([]migrationSetFactory, error) {
+	sets, err := c.migrationSetFactories()
+	if err != nil {
+		return nil, err
+	}
+
+	switch psqlmigrate.ActionSetOrder(action) {
+	case psqlmigrate.SINGLE_SET:
+		if len(sets) > 1 {
+			return nil, fmt.Errorf("Migrate action '%s' runs on a single migration set. Select one of '%s'", action, strings.Join(c.MigrationSetNames(), "', '"))
+		}
+	case psqlmigrate.REVERSE_ORDER:
+		slices.Reverse(sets)
+	}
+
+	return sets, nil
+}
+
+// singleMigrationSetFactory returns the provider factory of the selected
+// migration set, or an error if multiple migration sets are selected.
+func (c *Config) singleMigrationSetFactory() (*psqlmigrate.ProviderFactory, error) {
+	sets, err := c.migrationSetFactories()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sets) > 1 {
+		return nil, fmt.Errorf("Multiple migration sets selected. Select one of '%s'", strings.Join(c.MigrationSetNames(), "', '"))
+	}
+
+	return sets[0].factory, nil
+}
+
+// withoutVersionTables returns a copy of the schema options that excludes
+// the goose version tables, their checksum tables and the init tracking
+// table, as they are managed by psql-manager itself.
+func (c *Config) withoutVersionTables(opts db.SchemaOptions) *db.SchemaOptions {
+	tables := []string{c.migrationProviderFactory.GetVersionTable()}
+	for _, set := range c.migrationSets {
+		tables = append(tables, set.VersionTable)
+	}
+
+	opts.ExcludeRelations = slices.Clone(opts.ExcludeRelations)
+	for _, table := range tables {
+		opts.ExcludeRelations = append(opts.ExcludeRelations, table, psqlmigrate.ChecksumTableName(table))
+	}
+	if len(c.InitRunner.TrackingTable) > 0 {
+		opts.ExcludeRelations = append(opts.ExcludeRelations, c.InitRunner.TrackingTable)
+	}
+	return &opts
+}
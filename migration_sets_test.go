@@ -0,0 +1,98 @@
+package psqlmanager
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+)
+
+func setNames(sets []migrationSetFactory) []string {
+	names := make([]string, len(sets))
+	for i, s := range sets {
+		names[i] = s.name
+	}
+	return names
+}
+
+func TestMigrationSetFactoriesFor(t *testing.T) {
+	config, err := NewConfig(
+		WithMigrationSet("core", fstest.MapFS{}),
+		WithMigrationSet("billing", fstest.MapFS{}),
+		WithMigrationSet("audit", fstest.MapFS{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := config.migrationSetFactoriesFor(psqlmigrate.UpToLatestAction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := setNames(sets); !slices.Equal(names, []string{"core", "billing", "audit"}) {
+		t.Errorf("Expected declared order, got %v", names)
+	}
+	if sets[1].factory.GetVersionTable() != "goose_db_version_billing" {
+		t.Errorf("Expected version table of the set, got %s", sets[1].factory.GetVersionTable())
+	}
+
+	sets, err = config.migrationSetFactoriesFor(psqlmigrate.ResetAction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := setNames(sets); !slices.Equal(names, []string{"audit", "billing", "core"}) {
+		t.Errorf("Expected reverse order, got %v", names)
+	}
+
+	_, err = config.migrationSetFactoriesFor(psqlmigrate.UpToAction(3))
+	if err == nil || !strings.Contains(err.Error(), "single migration set") {
+		t.Errorf("Expected single set error, got %v", err)
+	}
+}
+
+func TestOnlyMigrationSets(t *testing.T) {
+	config, err := NewConfig(
+		WithMigrationSet("core", fstest.MapFS{}),
+		WithMigrationSet("billing", fstest.MapFS{}),
+		WithMigrationSet("audit", fstest.MapFS{}),
+		WithOnlyMigrationSets("audit", "core"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := config.migrationSetFactoriesFor(psqlmigrate.UpToLatestAction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := setNames(sets); !slices.Equal(names, []string{"core", "audit"}) {
+		t.Errorf("Expected selected sets in declared order, got %v", names)
+	}
+
+	_ = config.Extend(WithOnlyMigrationSets("billing"))
+	sets, err = config.migrationSetFactoriesFor(psqlmigrate.UpToAction(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := setNames(sets); !slices.Equal(names, []string{"billing"}) {
+		t.Errorf("Expected the single selected set, got %v", names)
+	}
+
+	_ = config.Extend(WithOnlyMigrationSets("missing"))
+	if _, err := config.migrationSetFactories(); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestOnlyMigrationSetsWithoutSets(t *testing.T) {
+	config, err := NewConfig(WithOnlyMigrationSets("core"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := config.migrationSetFactories(); err == nil {
+		t.Errorf("Expected an error when no migration sets are configured")
+	}
+}
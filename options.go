@@ -4,9 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
 
 	"github.com/pressly/goose/v3"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
 	psqlinit "github.com/shared-digitaltechnologies/psql-manager/init"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
 	psqlseed "github.com/shared-digitaltechnologies/psql-manager/seed"
 	"github.com/shared-digitaltechnologies/psql-manager/seed/fake"
 )
@@ -57,6 +63,13 @@ func (o *Config) ensureOwnsCurrentInitRepository() {
 	}
 }
 
+func (o *Config) ensureOwnsCurrentClusterInitRepository() {
+	if !o.ownsCurrentClusterInitRepository {
+		o.InitRunner.ClusterRepository = o.InitRunner.Cluster().Copy()
+		o.ownsCurrentClusterInitRepository = true
+	}
+}
+
 // CONNECT //
 
 // WithRootDbConnString sets the connection string to connect to the
@@ -114,6 +127,28 @@ func WithExtraInit(script psqlinit.InitScript, skipWhen ...psqlinit.Condition) C
 	}
 }
 
+// WithExtraClusterInit adds an additional init script that runs on the root
+// database before the target database is created, without changing the
+// global cluster init repository. Cluster init scripts run without a
+// transaction.
+func WithExtraClusterInit(script psqlinit.InitScript, skipWhen ...psqlinit.Condition) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsCurrentClusterInitRepository()
+		o.InitRunner.ClusterRepository.Add(script, skipWhen...)
+		return nil
+	}
+}
+
+// WithInitDir adds the `.sql` files in the directory as additional init
+// scripts, without changing the global init repository. See
+// psqlinit.Repository.AddDir.
+func WithInitDir(fsys fs.FS, dirpath ...string) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsCurrentInitRepository()
+		return o.InitRunner.Repository.AddDir(fsys, dirpath...)
+	}
+}
+
 // WithIgnoreInitConditions sets whether all init conditions should be
 // ignored (and thus, whether each init scripts should always run, regardless
 // of the skipWhen conditions defined for the init step).
@@ -124,6 +159,46 @@ func WithIgnoreInitConditions(value bool) ConfigOption {
 	}
 }
 
+// WithInitTracking tracks the applied init scripts with their checksums in
+// the table, and skips init scripts that were already applied with the same
+// content. Uses psqlinit.DefaultTrackingTable if the table is empty.
+//
+// The skipWhen conditions are still evaluated for scripts that are new or
+// changed.
+func WithInitTracking(table string) ConfigOption {
+	return func(o *Config) error {
+		if len(table) == 0 {
+			table = psqlinit.DefaultTrackingTable
+		}
+		o.InitRunner.TrackingTable = table
+		return nil
+	}
+}
+
+// WithFailOnChangedInit sets whether tracked init scripts that changed since
+// they were applied are an error, instead of being applied again.
+func WithFailOnChangedInit(value bool) ConfigOption {
+	return func(o *Config) error {
+		if value {
+			o.InitRunner.OnChanged = psqlinit.FAIL_ON_CHANGED
+		} else {
+			o.InitRunner.OnChanged = psqlinit.RERUN_CHANGED
+		}
+		return nil
+	}
+}
+
+// WithInitTransactionMode sets how the init scripts on the target database
+// are wrapped in transactions. Defaults to psqlinit.TRANSACTION_PER_STEP.
+// Wrap a script with psqlinit.NoTransaction to apply it outside a
+// transaction. Init scripts on the root database never run in a transaction.
+func WithInitTransactionMode(mode psqlinit.TransactionMode) ConfigOption {
+	return func(o *Config) error {
+		o.InitRunner.TransactionMode = mode
+		return nil
+	}
+}
+
 // SEEDERS //
 
 func (c *Config) ensureOwnsCurrentSeederRepository() {
@@ -202,6 +277,12 @@ func WithBailOnSeederError(value bool) ConfigOption {
 
 // MIGRATIONS //
 
+func (c *Config) ensureOwnsMigrationProviderFactory() {
+	if c.migrationProviderFactory == nil {
+		c.migrationProviderFactory = c.migrationProviderFactory.Copy()
+	}
+}
+
 // WithMigrationsDir sets the directory where the sql goose migrations
 // can be found.
 func WithMigrationsDir(fsys fs.FS, dirpath ...string) ConfigOption {
@@ -228,3 +309,270 @@ func WithGooseProviderOptions(options ...goose.ProviderOption) ConfigOption {
 		return nil
 	}
 }
+
+// WithMigrationLock sets the advisory lock that serializes concurrent
+// migrate actions on the same database. Disables locking if nil.
+func WithMigrationLock(lock *psqlmigrate.AdvisoryLock) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.SetLock(lock.Copy())
+		return nil
+	}
+}
+
+// WithMigrationLockId sets the id of the advisory lock that serializes
+// migrate actions.
+//
+// Defaults to psqlmigrate.DefaultLockId.
+func WithMigrationLockId(id int64) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		if o.migrationProviderFactory.Lock == nil {
+			o.migrationProviderFactory.Lock = psqlmigrate.DefaultAdvisoryLock()
+		}
+		o.migrationProviderFactory.Lock.Id = id
+		return nil
+	}
+}
+
+// WithMigrationLockWait sets how long to wait for the advisory migration
+// lock when another session holds it. Not to be confused with the Postgres
+// lock_timeout of the migration sessions, see WithMigrationTimeouts.
+//
+// Defaults to 5 minutes.
+func WithMigrationLockWait(timeout time.Duration) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		if o.migrationProviderFactory.Lock == nil {
+			o.migrationProviderFactory.Lock = psqlmigrate.DefaultAdvisoryLock()
+		}
+		o.migrationProviderFactory.Lock.WaitTimeout = timeout
+		return nil
+	}
+}
+
+// WithMigrationSet adds a named set of migrations that is tracked in its own
+// version table "goose_db_version_NAME". Migrate actions run on the
+// migration sets in the order in which they are added.
+func WithMigrationSet(name string, fsys fs.FS, dirpath ...string) ConfigOption {
+	return func(o *Config) error {
+		if slices.ContainsFunc(o.migrationSets, func(set psqlmigrate.MigrationSet) bool { return set.Name == name }) {
+			return fmt.Errorf("Migration set \"%s\" already exists", name)
+		}
+
+		set, err := psqlmigrate.NewMigrationSet(name, fsys, dirpath...)
+		if err != nil {
+			return err
+		}
+
+		o.migrationSets = append(slices.Clone(o.migrationSets), set)
+		return nil
+	}
+}
+
+// WithMigrationSetVersionTable sets the goose version table of the migration
+// set with the provided name.
+func WithMigrationSetVersionTable(name string, table string) ConfigOption {
+	return func(o *Config) error {
+		i := slices.IndexFunc(o.migrationSets, func(set psqlmigrate.MigrationSet) bool { return set.Name == name })
+		if i < 0 {
+			return fmt.Errorf("Migration set \"%s\" not found", name)
+		}
+
+		o.migrationSets = slices.Clone(o.migrationSets)
+		o.migrationSets[i].VersionTable = table
+		return nil
+	}
+}
+
+// WithOnlyMigrationSets restricts migrate actions to the migration sets
+// with the provided names. Selects all migration sets if empty.
+func WithOnlyMigrationSets(names ...string) ConfigOption {
+	return func(o *Config) error {
+		o.onlyMigrationSets = slices.Clone(names)
+		return nil
+	}
+}
+
+// WithStrictChecksums makes migrate actions refuse to run if applied
+// migrations were modified after they were applied.
+func WithStrictChecksums(value bool) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.StrictChecksums = value
+		return nil
+	}
+}
+
+// WithBeforeMigrationHook adds a hook that runs before each individual
+// migration. Migrations are applied one by one if any migration hooks are
+// registered.
+func WithBeforeMigrationHook(hook psqlmigrate.MigrationHook) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.Hooks.BeforeMigration = append(o.migrationProviderFactory.Hooks.BeforeMigration, hook)
+		return nil
+	}
+}
+
+// WithAfterMigrationHook adds a hook that runs after each individual
+// migration that succeeded.
+func WithAfterMigrationHook(hook psqlmigrate.MigrationHook) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.Hooks.AfterMigration = append(o.migrationProviderFactory.Hooks.AfterMigration, hook)
+		return nil
+	}
+}
+
+// WithBeforeActionHook adds a hook that runs before each migrate action.
+func WithBeforeActionHook(hook psqlmigrate.ActionHook) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.Hooks.BeforeAction = append(o.migrationProviderFactory.Hooks.BeforeAction, hook)
+		return nil
+	}
+}
+
+// WithAfterActionHook adds a hook that runs after each migrate action, also
+// if the action failed.
+func WithAfterActionHook(hook psqlmigrate.ActionHook) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.Hooks.AfterAction = append(o.migrationProviderFactory.Hooks.AfterAction, hook)
+		return nil
+	}
+}
+
+// WithAllowMissingMigrations applies pending migrations that are older than
+// the current version of the database, instead of failing.
+func WithAllowMissingMigrations(value bool) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.AllowMissing = value
+		return nil
+	}
+}
+
+// WithAtomicMigrations runs all migrations of a migrate action in a single
+// transaction, such that a failing migration rolls back the complete action.
+//
+// Actions that have to apply Go migrations or migrations marked with
+// `-- +goose NO TRANSACTION` are refused.
+func WithAtomicMigrations(value bool) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.Atomic = value
+		return nil
+	}
+}
+
+// SQL VARIABLES //
+
+// WithSqlVars enables the substitution of `${NAME}` placeholders in the SQL
+// migrations, init sql files and deterministic sql seeder files, and sets the
+// values of the variables.
+func WithSqlVars(values map[string]string) ConfigOption {
+	return func(o *Config) error {
+		vars := o.ensureSqlVars()
+		for k, v := range values {
+			vars.values[k] = v
+		}
+		return nil
+	}
+}
+
+// WithSqlVar enables the substitution of `${NAME}` placeholders and sets the
+// value of a single variable.
+func WithSqlVar(name string, value string) ConfigOption {
+	return WithSqlVars(map[string]string{name: value})
+}
+
+// WithStrictSqlVars enables the substitution of `${NAME}` placeholders and
+// makes undefined variables without a default an error.
+func WithStrictSqlVars(value bool) ConfigOption {
+	return func(o *Config) error {
+		o.ensureSqlVars().strict = value
+		return nil
+	}
+}
+
+// WithEnvSqlVars sets whether placeholders also resolve to environment
+// variables.
+//
+// Defaults to true.
+func WithEnvSqlVars(value bool) ConfigOption {
+	return func(o *Config) error {
+		o.ensureSqlVars().noEnv = !value
+		return nil
+	}
+}
+
+// TIMEOUTS //
+
+// WithMigrationTimeouts sets the lock, statement and idle-in-transaction
+// timeouts of the sessions that run the migrations.
+func WithMigrationTimeouts(timeouts db.SessionTimeouts) ConfigOption {
+	return func(o *Config) error {
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.Timeouts = timeouts
+		return nil
+	}
+}
+
+// WithInitTimeouts sets the lock, statement and idle-in-transaction timeouts
+// of the session that runs the init scripts.
+func WithInitTimeouts(timeouts db.SessionTimeouts) ConfigOption {
+	return func(o *Config) error {
+		o.InitRunner.Timeouts = timeouts
+		return nil
+	}
+}
+
+// WithSeedTimeouts sets the lock, statement and idle-in-transaction timeouts
+// of the session that runs the seeders.
+func WithSeedTimeouts(timeouts db.SessionTimeouts) ConfigOption {
+	return func(o *Config) error {
+		o.SeederRunner.Timeouts = timeouts
+		return nil
+	}
+}
+
+// WithLockTimeoutRetry retries migrations that failed with a lock timeout
+// up to attempts times in total. The wait time between attempts starts at
+// backoff and doubles after each retry.
+//
+// Migrations marked with `-- +goose NO TRANSACTION` are never retried.
+func WithLockTimeoutRetry(attempts int, backoff time.Duration) ConfigOption {
+	return func(o *Config) error {
+		if attempts < 1 {
+			return fmt.Errorf("Invalid number of lock timeout attempts %d: must be at least 1", attempts)
+		}
+		o.ensureOwnsMigrationProviderFactory()
+		o.migrationProviderFactory.Retry = psqlmigrate.RetryPolicy{
+			Attempts: attempts,
+			Backoff:  backoff,
+		}
+		return nil
+	}
+}
+
+// LOGGING //
+
+// WithLogger sets the logger of the reports of all phases. Use a logger with
+// a discarding handler to silence them.
+func WithLogger(logger *slog.Logger) ConfigOption {
+	return func(o *Config) error {
+		o.Logger = logger
+		return nil
+	}
+}
+
+// WithConsoleLogger logs the reports of all phases to stdout with a
+// ConsoleHandler.
+func WithConsoleLogger(color LogColorMode, verbosity LogVerbosity) ConfigOption {
+	return func(o *Config) error {
+		o.Logger = slog.New(NewConsoleHandler(os.Stdout, color, verbosity))
+		return nil
+	}
+}
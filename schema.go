@@ -0,0 +1,36 @@
+package psqlmanager
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+func inspectDatabaseSchema(ctx context.Context, rootConn *pgx.Conn, database *db.Database, opts *db.SchemaOptions) (*db.Schema, error) {
+	connConfig := rootConn.Config()
+	connConfig.Database = database.Name
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	return db.InspectSchema(ctx, conn, opts)
+}
+
+// DumpDatabaseSchema renders the schema of the database as normalized DDL.
+// The goose version tables are never included in the dump.
+func DumpDatabaseSchema(ctx context.Context, database *db.Database, opts db.SchemaOptions, config *Config) (string, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+
+	conn, err := ConnectDatabase(ctx, database, config)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(ctx)
+
+	return db.DumpSchema(ctx, conn, config.withoutVersionTables(opts))
+}
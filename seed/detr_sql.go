@@ -66,8 +66,16 @@ func (v *detrSqlFileSeeder) Prepare(ctx context.Context, seed fake.Seed) {
 }
 
 func (v *detrSqlFileSeeder) RunSeederTx(ctx context.Context, seed fake.Seed, tx db.Tx) error {
+	return v.RunSeederTxExpanded(ctx, seed, tx, nil)
+}
+
+func (v *detrSqlFileSeeder) RunSeederTxExpanded(ctx context.Context, seed fake.Seed, tx db.Tx, vars *db.Vars) error {
 	content, err := fs.ReadFile(v.fsys, v.filename)
-	sql := string(content)
+	if err != nil {
+		return err
+	}
+
+	sql, err := vars.Expand(string(content))
 	if err != nil {
 		return err
 	}
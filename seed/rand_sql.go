@@ -2,7 +2,6 @@ package psqlseed
 
 import (
 	"context"
-	"fmt"
 	"io/fs"
 	"strings"
 	"text/template"
@@ -78,7 +77,7 @@ func (v *templateSeeder) RunSeederTx(ctx context.Context, seed fake.Seed, tx db.
 	var err error
 	for sql := range v.sql {
 		if err == nil {
-			fmt.Println(sql)
+			db.Logger(ctx).Debug(sql, "seeder", v.Name())
 			_, err = tx.Exec(ctx, sql)
 			if err != nil {
 				err = db.ErrWithPgRowCol(err, v.Name(), sql)
@@ -2,7 +2,9 @@ package psqlseed
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -84,6 +86,12 @@ type Runner struct {
 	RunReports  []*RunReport
 	LogLevel    LogLevel
 	BailOnError bool
+
+	// Substitutes the placeholders in the SQL of ExpandingSeeders.
+	Vars *db.Vars
+
+	// Timeouts of the session while the seeders run.
+	Timeouts db.SessionTimeouts
 }
 
 func (r *Runner) SeedersNamed(seederNames ...string) error {
@@ -97,7 +105,15 @@ func (r *Runner) SeedersTill(seederNamePrefix string) {
 	r.Repository = &store
 }
 
-func (r *Runner) Run(ctx context.Context, conn *pgx.Conn) error {
+func (r *Runner) Run(ctx context.Context, conn *pgx.Conn) (err error) {
+	resetTimeouts, err := r.Timeouts.Apply(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, resetTimeouts(ctx))
+	}()
+
 	seeders := r.Repository.Seeders()
 	for _, seeder := range seeders {
 		seeder.Prepare(ctx, r.Seed)
@@ -133,7 +149,11 @@ func (r *Runner) runSeeder(ctx context.Context, conn *pgx.Conn, seeder Seeder) e
 	}
 
 	report.StartedAt = time.Now()
-	err = seeder.RunSeederTx(ctx, r.Seed, tx)
+	if s, ok := seeder.(ExpandingSeeder); ok && r.Vars != nil {
+		err = s.RunSeederTxExpanded(ctx, r.Seed, tx, r.Vars)
+	} else {
+		err = seeder.RunSeederTx(ctx, r.Seed, tx)
+	}
 	report.EndedAt = time.Now()
 
 	if err != nil {
@@ -155,7 +175,20 @@ func (r *Runner) runSeeder(ctx context.Context, conn *pgx.Conn, seeder Seeder) e
 	}
 
 	if r.LogLevel > NONE {
-		fmt.Printf("%s\n", &report)
+		level := slog.LevelInfo
+		if report.Err != nil {
+			level = slog.LevelError
+		}
+		attrs := []any{
+			db.Indent(1),
+			db.StatusKey, report.Status.String(),
+			db.DurationKey, report.Duration(),
+			"seeder", seeder.Name(),
+		}
+		db.Logger(ctx).Log(ctx, level, seeder.Name(), attrs...)
+		if report.Err != nil {
+			db.Logger(ctx).Error(report.Err.Error(), db.Indent(2), "seeder", seeder.Name())
+		}
 	}
 
 	if r.BailOnError && report.Err != nil {
@@ -15,6 +15,13 @@ type Seeder interface {
 	RunSeederTx(context.Context, fake.Seed, db.Tx) error
 }
 
+// ExpandingSeeder is a Seeder with SQL that can contain `${NAME}`
+// placeholders. The Runner runs it with its variables if it has any.
+type ExpandingSeeder interface {
+	Seeder
+	RunSeederTxExpanded(context.Context, fake.Seed, db.Tx, *db.Vars) error
+}
+
 var (
 	RandSeederIdNs                 uuid.UUID = uuid.MustParse("8b8f98b6-eb5c-44e0-a68e-cdcc22e1c366")
 	RandSeederRunIdNs              uuid.UUID = uuid.MustParse("7d894063-16a6-416a-a822-1bb0ca4bfe72")
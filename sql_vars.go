@@ -0,0 +1,63 @@
+package psqlmanager
+
+import (
+	"maps"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+type sqlVarsConfig struct {
+	values map[string]string
+	strict bool
+	noEnv  bool
+}
+
+func (c *sqlVarsConfig) copy() *sqlVarsConfig {
+	if c == nil {
+		return nil
+	}
+	res := *c
+	res.values = maps.Clone(c.values)
+	return &res
+}
+
+// SqlVars returns the variables that substitute the `${NAME}` placeholders
+// in the SQL that runs in the database, or nil if substitution is disabled.
+//
+// Besides the configured values and the environment, PGHOST, PGPORT, PGUSER
+// and PGDATABASE resolve to the connection settings.
+func (c *Config) SqlVars(database *db.Database) *db.Vars {
+	if c == nil {
+		c = &GlobalConfig
+	}
+
+	if c.sqlVars == nil {
+		return nil
+	}
+
+	if database == nil {
+		database = c.TargetDatabase()
+	}
+
+	values := make(map[string]string, len(c.sqlVars.values)+4)
+	for env, key := range map[string]string{"PGHOST": "host", "PGPORT": "port", "PGUSER": "user"} {
+		if val, ok := c.ConnString.Get(key); ok {
+			values[env] = val
+		}
+	}
+	values["PGDATABASE"] = database.Name
+	maps.Copy(values, c.sqlVars.values)
+
+	return &db.Vars{
+		Values:    values,
+		LookupEnv: !c.sqlVars.noEnv,
+		Strict:    c.sqlVars.strict,
+	}
+}
+
+func (c *Config) ensureSqlVars() *sqlVarsConfig {
+	if c.sqlVars == nil {
+		c.sqlVars = &sqlVarsConfig{values: make(map[string]string)}
+	}
+	return c.sqlVars
+}
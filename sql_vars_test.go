@@ -0,0 +1,56 @@
+package psqlmanager
+
+import (
+	"testing"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+)
+
+func TestConfigSqlVars(t *testing.T) {
+	config, err := NewConfig(WithConnString("host=db.example.com port=5433 user=app dbname=app_dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.SqlVars(nil) != nil {
+		t.Errorf("expected no vars without sql vars options")
+	}
+
+	config, err = NewConfig(
+		WithConnString("host=db.example.com port=5433 user=app dbname=app_dev"),
+		WithSqlVar("ROLE", "app_rw"),
+		WithStrictSqlVars(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := config.SqlVars(nil)
+	expected := map[string]string{
+		"PGHOST":     "db.example.com",
+		"PGPORT":     "5433",
+		"PGUSER":     "app",
+		"PGDATABASE": "app_dev",
+		"ROLE":       "app_rw",
+	}
+	for name, value := range expected {
+		if vars.Values[name] != value {
+			t.Errorf("%s: expected %q, got %q", name, value, vars.Values[name])
+		}
+	}
+	if !vars.Strict || !vars.LookupEnv {
+		t.Errorf("expected strict vars that look up the environment, got %+v", vars)
+	}
+
+	if vars := config.SqlVars(&db.Database{Name: "other"}); vars.Values["PGDATABASE"] != "other" {
+		t.Errorf("expected PGDATABASE of the given database, got %q", vars.Values["PGDATABASE"])
+	}
+
+	err = config.Extend(WithSqlVars(map[string]string{"PGUSER": "owner", "PGDATABASE": "app_test"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars = config.SqlVars(nil)
+	if vars.Values["PGUSER"] != "owner" || vars.Values["PGDATABASE"] != "app_test" {
+		t.Errorf("expected configured values to override the connection settings, got %v", vars.Values)
+	}
+}
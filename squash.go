@@ -0,0 +1,214 @@
+package psqlmanager
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pressly/goose/v3"
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+)
+
+// SquashAction replaces all migrations up to and including version UpTo
+// with a single baseline migration that creates the schema at that version.
+//
+// The baseline migration gets version UpTo, such that databases that are
+// already at or past UpTo consider it applied. The checksum of the baseline
+// is not verified, as it differs from the checksum recorded for the last
+// superseded migration.
+//
+// The baseline does not contain the objects that the init scripts create,
+// as the init scripts run before the migrations. Changes of the migrations
+// to those objects are not part of the baseline either.
+type SquashAction struct {
+	UpTo int64
+
+	// Directory on disk with the migrations of the (selected) migration set.
+	Dir string
+
+	// Directory to move the superseded migrations to.
+	//
+	// Defaults to the "archive" directory in Dir.
+	ArchiveDir string
+}
+
+type squashFile struct {
+	name    string
+	version int64
+}
+
+func (a *SquashAction) archiveDir() string {
+	if len(a.ArchiveDir) == 0 {
+		return filepath.Join(a.Dir, "archive")
+	}
+	return a.ArchiveDir
+}
+
+// supersededFiles returns the migration files in Dir with a version up to
+// and including UpTo, ordered by version.
+func (a *SquashAction) supersededFiles() ([]squashFile, error) {
+	entries, err := os.ReadDir(a.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []squashFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		if ext != ".sql" && ext != ".go" {
+			continue
+		}
+
+		version, err := goose.NumericComponent(entry.Name())
+		if err != nil || version > a.UpTo {
+			continue
+		}
+
+		if ext == ".go" {
+			return nil, fmt.Errorf("Cannot squash Go migration '%s'", entry.Name())
+		}
+
+		res = append(res, squashFile{entry.Name(), version})
+	}
+
+	slices.SortFunc(res, func(a, b squashFile) int {
+		return cmp.Compare(a.version, b.version)
+	})
+
+	if len(res) == 0 || res[len(res)-1].version != a.UpTo {
+		return nil, fmt.Errorf("No migration with version %d in '%s'", a.UpTo, a.Dir)
+	}
+
+	return res, nil
+}
+
+func baselineMigration(version int64, ddl string) string {
+	// Keep the setting of the dump to the transaction of the migration, as
+	// the migration runs on a pooled connection.
+	ddl = strings.Replace(ddl, "SET check_function_bodies", "SET LOCAL check_function_bodies", 1)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Baseline of all migrations up to version %d.\n", version)
+	b.WriteString("-- The superseded migrations are archived.\n\n")
+	b.WriteString("-- +goose Up\n-- +goose StatementBegin\n")
+	b.WriteString(ddl)
+	b.WriteString("-- +goose StatementEnd\n\n")
+	b.WriteString("-- +goose Down\n-- The baseline cannot be rolled back.\n")
+	b.WriteString("-- +goose StatementBegin\n")
+	fmt.Fprintf(&b, "DO $$ BEGIN RAISE EXCEPTION 'baseline %% cannot be rolled back', %d; END $$;\n", version)
+	b.WriteString("-- +goose StatementEnd\n")
+	return b.String()
+}
+
+func (a *SquashAction) RunWithRootConn(ctx context.Context, rootConn *pgx.Conn, config *Config) (string, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+
+	files, err := a.supersededFiles()
+	if err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: %w", a.UpTo, err)
+	}
+
+	baselinePath := filepath.Join(a.Dir, psqlmigrate.BaselineFileName(a.UpTo))
+	archiveDir := a.archiveDir()
+	for _, f := range files {
+		_, err := os.Stat(filepath.Join(archiveDir, f.name))
+		if err == nil {
+			return "", fmt.Errorf("Failed SquashAction %d: Migration '%s' already exists in archive '%s'", a.UpTo, f.name, archiveDir)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("Failed SquashAction %d: %w", a.UpTo, err)
+		}
+	}
+
+	factory, err := config.singleMigrationSetFactory()
+	if err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: %w", a.UpTo, err)
+	}
+	factory = factory.Copy()
+	factory.MigrationsFsys = os.DirFS(a.Dir)
+
+	// Build the schema at version UpTo in a temporary database.
+	tempAction := InitDatabaseAction{
+		Database:   &db.Database{Name: config.TargetDatabase().Name + "_squash"},
+		Create:     true,
+		TempSuffix: true,
+	}
+	temp, err := tempAction.RunWithRootConn(ctx, rootConn, config)
+	if err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: Temp: %w", a.UpTo, err)
+	}
+	defer func() {
+		_, err := dropDatabaseIfExists(ctx, rootConn, temp, config)
+		if err != nil {
+			config.warnDropFailed(temp.Name, err)
+		}
+	}()
+
+	// Snapshot the schema that the init scripts created, as the init scripts
+	// run before the baseline on every new database.
+	opts := config.withoutVersionTables(db.SchemaOptions{
+		ExcludeRelations: []string{
+			factory.GetVersionTable(),
+			psqlmigrate.ChecksumTableName(factory.GetVersionTable()),
+		},
+	})
+	initSchema, err := inspectDatabaseSchema(ctx, rootConn, temp, opts)
+	if err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: Dump init: %w", a.UpTo, err)
+	}
+
+	err = runMigrateActionWithFactory(ctx, psqlmigrate.UpToAction(a.UpTo), temp, config, factory, true)
+	if err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: Migrate: %w", a.UpTo, err)
+	}
+
+	schema, err := inspectDatabaseSchema(ctx, rootConn, temp, opts)
+	if err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: Dump: %w", a.UpTo, err)
+	}
+	schema = schema.Without(initSchema)
+
+	// Archive the superseded migrations before writing the baseline, as the
+	// baseline has the same version as the last superseded migration.
+	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: Archive: %w", a.UpTo, err)
+	}
+	for _, f := range files {
+		err := os.Rename(filepath.Join(a.Dir, f.name), filepath.Join(archiveDir, f.name))
+		if err != nil {
+			return "", fmt.Errorf("Failed SquashAction %d: Archive: %w", a.UpTo, err)
+		}
+	}
+	config.logger().Info(fmt.Sprintf("ARCHIVED %d migrations in '%s'", len(files), archiveDir), db.Phase("squash"), "dir", archiveDir)
+
+	err = os.WriteFile(baselinePath, []byte(baselineMigration(a.UpTo, schema.DDL())), 0o644)
+	if err != nil {
+		return "", fmt.Errorf("Failed SquashAction %d: Baseline: %w", a.UpTo, err)
+	}
+	config.logger().Info(fmt.Sprintf("WROTE baseline migration '%s'", baselinePath), db.Phase("squash"), "path", baselinePath)
+
+	return baselinePath, nil
+}
+
+func (a *SquashAction) Run(ctx context.Context, config *Config) (string, error) {
+	rootConn, err := ConnectRootDB(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	defer rootConn.Close(ctx)
+
+	return a.RunWithRootConn(ctx, rootConn, config)
+}
@@ -0,0 +1,71 @@
+package psqlmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSquashDir(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSupersededFiles(t *testing.T) {
+	dir := writeSquashDir(t, "00003_c.sql", "00001_a.sql", "00002_b.sql", "00004_d.go", "README.md")
+
+	files, err := (&SquashAction{UpTo: 3, Dir: dir}).supersededFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	if expected := "00001_a.sql 00002_b.sql 00003_c.sql"; strings.Join(names, " ") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(names, " "))
+	}
+}
+
+func TestSupersededFilesErrors(t *testing.T) {
+	cases := map[string]*SquashAction{
+		"go migration":    {UpTo: 2, Dir: writeSquashDir(t, "00001_a.sql", "00002_b.go")},
+		"missing version": {UpTo: 3, Dir: writeSquashDir(t, "00001_a.sql", "00002_b.sql")},
+		"no migrations":   {UpTo: 1, Dir: writeSquashDir(t)},
+	}
+	for name, action := range cases {
+		if _, err := action.supersededFiles(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBaselineMigration(t *testing.T) {
+	res := baselineMigration(3, "SET check_function_bodies = false;\nCREATE TABLE \"public\".\"users\" ();\n")
+
+	expected := []string{
+		"-- +goose Up\n-- +goose StatementBegin\n",
+		"SET LOCAL check_function_bodies = false;\n",
+		"CREATE TABLE \"public\".\"users\" ();\n",
+		"-- +goose StatementEnd\n",
+		"-- +goose Down\n",
+		"-- +goose StatementBegin\n",
+		"RAISE EXCEPTION 'baseline % cannot be rolled back', 3;",
+		"-- +goose StatementEnd\n",
+	}
+	offset := 0
+	for _, part := range expected {
+		i := strings.Index(res[offset:], part)
+		if i < 0 {
+			t.Fatalf("Expected %q after offset %d in:\n%s", part, offset, res)
+		}
+		offset += i + len(part)
+	}
+}
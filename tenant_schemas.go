@@ -0,0 +1,133 @@
+package psqlmanager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shared-digitaltechnologies/psql-manager/db"
+	psqlmigrate "github.com/shared-digitaltechnologies/psql-manager/migrate"
+)
+
+// SchemasOpts determine on which tenant schemas of a database the
+// migrations are applied, and how.
+type SchemasOpts struct {
+	// Glob pattern, or regular expression wrapped in slashes, that is
+	// matched against the names of the schemas.
+	Pattern string
+
+	// Query that returns the names of the schemas in its first column. Used
+	// instead of the pattern if set.
+	Query string
+
+	// Maximum number of schemas that are migrated at the same time. Migrates
+	// one schema at a time if less than 1.
+	Concurrency int
+
+	// Do not start on any more schemas after the action failed on one.
+	FailFast bool
+}
+
+func (o *SchemasOpts) String() string {
+	if len(o.Query) > 0 {
+		return "returned by the schema query"
+	}
+	return fmt.Sprintf("matching \"%s\"", o.Pattern)
+}
+
+// TenantSchemas returns the names of the tenant schemas in the database.
+func TenantSchemas(ctx context.Context, database *db.Database, opts *SchemasOpts, config *Config) ([]string, error) {
+	conn, err := ConnectDatabase(ctx, database, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	if len(opts.Query) > 0 {
+		return db.QuerySchemas(ctx, conn, opts.Query)
+	}
+
+	pattern, err := db.ParseNamePattern(opts.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return db.MatchingSchemas(ctx, conn, pattern)
+}
+
+// schemaResults returns an empty result for each tenant schema in the
+// database.
+func schemaResults(ctx context.Context, database *db.Database, opts *SchemasOpts, config *Config) ([]*DatabaseResult, error) {
+	schemas, err := TenantSchemas(ctx, database, opts, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("No schemas %s in database \"%s\"", opts, database.Name)
+	}
+
+	results := make([]*DatabaseResult, len(schemas))
+	for i, schema := range schemas {
+		results[i] = &DatabaseResult{Database: database.Name, Schema: schema}
+	}
+	return results, nil
+}
+
+// RunMigrateActionPerSchema runs the migrate action on each tenant schema
+// in the database. Each schema has its own version table and search_path.
+// Returns a DatabasesError if it failed on any of the schemas.
+//
+// The logs of the schemas interleave if the concurrency is larger than 1.
+func RunMigrateActionPerSchema(ctx context.Context, action psqlmigrate.MigrateAction, database *db.Database, opts *SchemasOpts, config *Config) ([]*DatabaseResult, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+	if database == nil {
+		database = config.TargetDatabase()
+	}
+
+	config.logger().Info(fmt.Sprintf("%s on schemas %s", action, opts), db.Phase("migrate"))
+
+	results, err := schemaResults(ctx, database, opts, config)
+	if err != nil {
+		return nil, err
+	}
+
+	runConcurrently(ctx, results, opts.Concurrency, opts.FailFast, func(ctx context.Context, i int) error {
+		config.logger().Info(fmt.Sprintf("SCHEMA \"%s\"", results[i].Schema), db.Phase("migrate"), "schema", results[i].Schema)
+		return runMigrateActionInSchema(ctx, action, database, results[i].Schema, config, true)
+	})
+
+	return results, databasesError(ctx, results)
+}
+
+// MigrationStatusReportPerSchema reads the migration status of each tenant
+// schema in the database. Returns a DatabasesError if it failed to read the
+// status of any of them.
+func MigrationStatusReportPerSchema(ctx context.Context, database *db.Database, opts *SchemasOpts, config *Config) ([]*DatabaseStatusReport, error) {
+	if config == nil {
+		config = &GlobalConfig
+	}
+	if database == nil {
+		database = config.TargetDatabase()
+	}
+
+	results, err := schemaResults(ctx, database, opts, config)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*DatabaseStatusReport, len(results))
+	for i := range reports {
+		reports[i] = &DatabaseStatusReport{}
+	}
+
+	runConcurrently(ctx, results, opts.Concurrency, opts.FailFast, func(ctx context.Context, i int) (err error) {
+		reports[i].Reports, err = migrationStatusReportInSchema(ctx, database, results[i].Schema, config)
+		return err
+	})
+
+	for i, result := range results {
+		reports[i].DatabaseResult = *result
+	}
+
+	return reports, databasesError(ctx, results)
+}